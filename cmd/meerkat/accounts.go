@@ -0,0 +1,232 @@
+package meerkat
+
+import (
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/ahmdrz/goinsta"
+)
+
+// Account is a watcher account of Instagram.
+type Account struct {
+	Username string
+	Password string
+	// Proxy of all requests of the account, ex. http://<ip>:<port>
+	Proxy string
+}
+
+// accountCooldown is the time an account is not used
+// after it is rate limited or can not log in.
+const accountCooldown = 30 * time.Minute
+
+// account is a watcher account of the pool and its status.
+// Status fields are guarded by m.mu, the others are only used by the watcher.
+type account struct {
+	Account
+	sessionFile string
+	instagram   *goinsta.Instagram
+
+	// proxy is the proxy of the pool used by the account
+	proxy *proxy
+
+	login     bool
+	down      bool
+	downUntil time.Time
+	lastError string
+	checks    int
+	failures  int
+}
+
+// loadAccounts makes the pool of watcher accounts, username and password
+// is the first account. The first account keeps sessionfile, the others
+// store their session next to it.
+func (m *Meerkat) loadAccounts() error {
+	accounts := m.Accounts
+	if m.Username != "" || m.Password != "" {
+		accounts = append([]Account{{Username: m.Username, Password: m.Password}}, accounts...)
+	}
+	if len(accounts) == 0 {
+		return fmt.Errorf("There is no username or accounts in yaml config file")
+	}
+
+	seen := make(map[string]bool)
+	for i, a := range accounts {
+		if a.Username == "" || a.Password == "" {
+			return fmt.Errorf("Account %d needs username and password", i+1)
+		}
+		if seen[a.Username] {
+			return fmt.Errorf("Account %s is repeated", a.Username)
+		}
+		seen[a.Username] = true
+
+		sessionFile := m.SessionFile
+		if i > 0 {
+			sessionFile = m.SessionFile + "." + a.Username
+		}
+		m.accounts = append(m.accounts, &account{Account: a, sessionFile: sessionFile})
+	}
+	return nil
+}
+
+// loginAccounts logs in all accounts, it only fails when no account can log in.
+func (m *Meerkat) loginAccounts() error {
+	m.assignProxies()
+
+	var lastErr error
+	for _, acc := range m.accounts {
+		if err := m.loginAccount(acc); err != nil {
+			lastErr = err
+			m.accountDown(acc, err)
+		}
+	}
+	if len(m.availableAccounts()) == 0 {
+		return lastErr
+	}
+	return nil
+}
+
+// use makes acc the account of the next requests.
+func (m *Meerkat) use(acc *account) {
+	m.account = acc
+	m.instagram = acc.instagram
+
+	m.mu.Lock()
+	acc.checks++
+	m.mu.Unlock()
+}
+
+func (m *Meerkat) availableAccounts() []*account {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	available := []*account{}
+	for _, acc := range m.accounts {
+		if acc.login && !acc.down {
+			available = append(available, acc)
+		}
+	}
+	return available
+}
+
+// pickAccount returns the account which checks username. Targets are spread
+// over available accounts by a hash of their username, so a target is checked
+// by the same account as long as the pool does not change.
+func (m *Meerkat) pickAccount(username string) *account {
+	available := m.availableAccounts()
+	if len(available) == 0 {
+		return nil
+	}
+	h := fnv.New32a()
+	h.Write([]byte(username))
+	return available[h.Sum32()%uint32(len(available))]
+}
+
+// accountDown takes acc out of the pool for accountCooldown,
+// its targets are checked by the other accounts in the meantime.
+func (m *Meerkat) accountDown(acc *account, err error) {
+	m.mu.Lock()
+	wasDown := acc.down
+	acc.down = true
+	acc.downUntil = time.Now().Add(accountCooldown)
+	acc.lastError = err.Error()
+	acc.failures++
+	m.mu.Unlock()
+
+	m.logger.Printf("Error, account %s is not used for %s, %s", acc.Username, accountCooldown, err.Error())
+
+	if !wasDown {
+		m.send(&Event{
+			Kind:          EventAccountDown,
+			OtherUsername: acc.Username,
+			Field:         "error",
+			NewValue:      err.Error(),
+			Timestamp:     time.Now(),
+		})
+	}
+}
+
+// reviveAccounts returns accounts to the pool after their cooldown,
+// and logs in again the ones which were logged out.
+func (m *Meerkat) reviveAccounts() {
+	now := time.Now()
+	for _, acc := range m.accounts {
+		m.mu.Lock()
+		due := acc.down && !now.Before(acc.downUntil)
+		login := acc.login
+		m.mu.Unlock()
+		if !due {
+			continue
+		}
+
+		if !login {
+			if err := m.loginAccount(acc); err != nil {
+				m.accountDown(acc, err)
+				continue
+			}
+		}
+
+		m.mu.Lock()
+		acc.down = false
+		m.mu.Unlock()
+
+		m.logger.Printf("Account %s is used again", acc.Username)
+		m.send(&Event{
+			Kind:          EventAccountUp,
+			OtherUsername: acc.Username,
+			Timestamp:     now,
+		})
+	}
+}
+
+// tryAccount runs fn with acc. When acc is logged out, it logs in again and
+// retries fn. It returns false when acc is logged out or rate limited, then
+// acc is taken out of the pool and fn should be retried with another account.
+func (m *Meerkat) tryAccount(acc *account, fn func() error) (bool, error) {
+	m.use(acc)
+	err := fn()
+	m.proxyResult(acc, err)
+	if classifyError(err) == errorLoggedOut {
+		if err := m.relogin(acc); err != nil {
+			m.accountDown(acc, err)
+			return false, err
+		}
+		m.use(acc)
+		err = fn()
+		m.proxyResult(acc, err)
+	}
+
+	switch classifyError(err) {
+	case errorLoggedOut, errorRateLimited:
+		m.accountDown(acc, err)
+		return false, err
+	}
+	return true, err
+}
+
+// accountsStatus is a line of status for each account.
+func (m *Meerkat) accountsStatus() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	lines := []string{}
+	for _, acc := range m.accounts {
+		state := "ok"
+		if acc.down {
+			state = "down until " + acc.downUntil.Format("15:04:05")
+		} else if !acc.login {
+			state = "logged out"
+		}
+		line := fmt.Sprintf("%s : %s, %d checks, %d failures", acc.Username, state, acc.checks, acc.failures)
+		if acc.Proxy != "" {
+			line += ", via " + proxyHost(acc.Proxy)
+		} else if acc.proxy != nil {
+			line += ", via " + proxyHost(acc.proxy.url)
+		}
+		if acc.lastError != "" {
+			line += ", last error : " + acc.lastError
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
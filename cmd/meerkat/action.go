@@ -0,0 +1,147 @@
+package meerkat
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ActionType is the type of a story of the following activity feed.
+type ActionType string
+
+const (
+	ActionLikedPost    ActionType = "liked_post"
+	ActionLikedComment ActionType = "liked_comment"
+	ActionCommented    ActionType = "commented"
+	ActionFollowed     ActionType = "started_following"
+	ActionTagged       ActionType = "tagged"
+	// ActionOther is a story which is not recognized.
+	ActionOther ActionType = "other"
+)
+
+var actionTypes = []ActionType{ActionLikedPost, ActionLikedComment, ActionCommented, ActionFollowed, ActionTagged, ActionOther}
+
+// Action is a story of the following activity feed,
+// the actor liked, commented, followed or tagged the other users or their media.
+type Action struct {
+	Type      ActionType   `json:"type"`
+	ActorID   int64        `json:"actor_id"`
+	Actor     string       `json:"actor,omitempty"`
+	Users     []ActionUser `json:"users,omitempty"`
+	MediaIDs  []string     `json:"media_ids,omitempty"`
+	CommentID int64        `json:"comment_id,omitempty"`
+}
+
+// ActionUser is a user linked in the text of an action.
+type ActionUser struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username,omitempty"`
+}
+
+// involved returns the actor and the other users of the action.
+func (a *Action) involved() []ActionUser {
+	users := []ActionUser{}
+	if a.ActorID != 0 {
+		users = append(users, ActionUser{ID: a.ActorID, Username: a.Actor})
+	}
+	return append(users, a.Users...)
+}
+
+// parseAction turns a story into an action. Users are the links of type "user",
+// the one at the start of the text is the actor. The type is recognized from
+// the text before the comment body without the linked usernames.
+func parseAction(story followingStory) *Action {
+	text := []rune(story.Args.Text)
+	linked := make([]bool, len(text))
+
+	action := &Action{Type: ActionOther, CommentID: story.Args.CommentID}
+	for _, link := range story.Args.Links {
+		if link.Type != "user" {
+			continue
+		}
+		id, err := strconv.ParseInt(link.ID, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		username := ""
+		if link.Start >= 0 && link.Start < link.End && link.End <= len(text) {
+			username = string(text[link.Start:link.End])
+			for i := link.Start; i < link.End; i++ {
+				linked[i] = true
+			}
+		}
+
+		if link.Start == 0 && action.ActorID == 0 {
+			action.ActorID, action.Actor = id, username
+		} else {
+			action.Users = append(action.Users, ActionUser{ID: id, Username: username})
+		}
+	}
+	if action.ActorID == 0 {
+		action.ActorID = story.Args.ProfileID
+	}
+
+	for _, media := range story.Args.Media {
+		action.MediaIDs = append(action.MediaIDs, media.ID)
+	}
+
+	// the verb phrase ends where the comment body starts,
+	// so words written by users are not mistaken for verbs
+	verb := []rune{}
+	for i, r := range text {
+		if linked[i] {
+			continue
+		}
+		if r == ':' || r == '"' || r == '\u201c' {
+			break
+		}
+		verb = append(verb, r)
+	}
+	action.Type = actionType(strings.ToLower(string(verb)), story.Args.CommentID)
+	return action
+}
+
+func actionType(verb string, commentID int64) ActionType {
+	switch {
+	case strings.Contains(verb, "started following"):
+		return ActionFollowed
+	case strings.Contains(verb, "liked") && strings.Contains(verb, "comment"):
+		return ActionLikedComment
+	case strings.Contains(verb, "liked"):
+		return ActionLikedPost
+	case strings.Contains(verb, "comment") || strings.Contains(verb, "replied") || commentID != 0:
+		return ActionCommented
+	case strings.Contains(verb, "tagged"):
+		return ActionTagged
+	}
+	return ActionOther
+}
+
+func checkActionTypes(types []string) error {
+	for _, t := range types {
+		known := false
+		for _, actionType := range actionTypes {
+			if ActionType(t) == actionType {
+				known = true
+			}
+		}
+		if !known {
+			return fmt.Errorf("Unknown activity type %s", t)
+		}
+	}
+	return nil
+}
+
+// watchedAction reports if activitytypes is empty or contains the type of action.
+func (m *Meerkat) watchedAction(action *Action) bool {
+	if len(m.ActivityTypes) == 0 {
+		return true
+	}
+	for _, t := range m.ActivityTypes {
+		if ActionType(t) == action.Type {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package meerkat
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// story builds a following activity story from an ASCII text, users are
+// linked at their first position after the previous user, with IDs from 1.
+func story(text string, commentID int64, users ...string) followingStory {
+	s := followingStory{}
+	s.Args.Text = text
+	s.Args.CommentID = commentID
+
+	from := 0
+	for i, username := range users {
+		start := from + strings.Index(text[from:], username)
+		end := start + len(username)
+		s.Args.Links = append(s.Args.Links, struct {
+			Start int    `json:"start"`
+			ID    string `json:"id"`
+			End   int    `json:"end"`
+			Type  string `json:"type"`
+		}{Start: start, ID: strconv.Itoa(i + 1), End: end, Type: "user"})
+		from = end
+	}
+	return s
+}
+
+func TestParseActionType(t *testing.T) {
+	tests := []struct {
+		story followingStory
+		want  ActionType
+	}{
+		{story("alice liked bob's post.", 0, "alice", "bob"), ActionLikedPost},
+		{story("alice liked 3 posts.", 0, "alice"), ActionLikedPost},
+		{story("alice liked bob's video.", 0, "alice", "bob"), ActionLikedPost},
+		{story("alice liked bob's comment: great shot", 7, "alice", "bob"), ActionLikedComment},
+		{story("alice commented on bob's post: I liked this comment section", 7, "alice", "bob"), ActionCommented},
+		{story("alice commented on bob's post: I started following you", 7, "alice", "bob"), ActionCommented},
+		{story("alice left a comment on bob's post: you were tagged", 7, "alice", "bob"), ActionCommented},
+		{story("alice replied to bob's comment: thanks", 7, "alice", "bob"), ActionCommented},
+		{story("alice mentioned bob in a comment: look", 7, "alice", "bob"), ActionCommented},
+		{story("alice started following bob.", 0, "alice", "bob"), ActionFollowed},
+		{story("alice started following bob and carol.", 0, "alice", "bob", "carol"), ActionFollowed},
+		{story("alice tagged bob in a post.", 0, "alice", "bob"), ActionTagged},
+		{story("alice was tagged in bob's post.", 0, "alice", "bob"), ActionTagged},
+		{story("likedfollower started following commenter.", 0, "likedfollower", "commenter"), ActionFollowed},
+		{story("alice shared something new.", 0, "alice"), ActionOther},
+	}
+
+	for _, test := range tests {
+		if got := parseAction(test.story).Type; got != test.want {
+			t.Errorf("%q is %s, want %s", test.story.Args.Text, got, test.want)
+		}
+	}
+}
+
+func TestParseActionUsers(t *testing.T) {
+	s := story("alice liked bob's comment: hi", 9, "alice", "bob")
+	s.Args.Media = append(s.Args.Media, struct {
+		Image string `json:"image"`
+		ID    string `json:"id"`
+	}{ID: "100_2"})
+
+	action := parseAction(s)
+	if action.ActorID != 1 || action.Actor != "alice" {
+		t.Errorf("actor is %d %s, want 1 alice", action.ActorID, action.Actor)
+	}
+	if len(action.Users) != 1 || action.Users[0].ID != 2 || action.Users[0].Username != "bob" {
+		t.Errorf("users are %v, want [2 bob]", action.Users)
+	}
+	if len(action.MediaIDs) != 1 || action.MediaIDs[0] != "100_2" {
+		t.Errorf("media IDs are %v, want [100_2]", action.MediaIDs)
+	}
+	if action.CommentID != 9 {
+		t.Errorf("comment ID is %d, want 9", action.CommentID)
+	}
+}
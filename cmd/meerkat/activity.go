@@ -0,0 +1,142 @@
+package meerkat
+
+import (
+	"fmt"
+	"time"
+)
+
+// seenStories is the number of story IDs kept to report each activity once.
+const seenStories = 5000
+
+// followingStory is a story of the following activity feed, it has the
+// element type of FollowingRecentActivityResponse.Stories to convert them.
+type followingStory struct {
+	Pk     string `json:"pk"`
+	Counts struct {
+	} `json:"counts"`
+	Type int `json:"type"`
+	Args struct {
+		Media []struct {
+			Image string `json:"image"`
+			ID    string `json:"id"`
+		} `json:"media"`
+		Text         string `json:"text"`
+		CommentID    int64  `json:"comment_id"`
+		ProfileImage string `json:"profile_image"`
+		Timestamp    int    `json:"timestamp"`
+		Links        []struct {
+			Start int    `json:"start"`
+			ID    string `json:"id"`
+			End   int    `json:"end"`
+			Type  string `json:"type"`
+		} `json:"links"`
+		ProfileID int64 `json:"profile_id"`
+	} `json:"args"`
+}
+
+// storyKey identifies a story, stories without pk are identified by their content.
+func storyKey(story followingStory) string {
+	if story.Pk != "" {
+		return story.Pk
+	}
+	return fmt.Sprintf("%d:%d:%s", story.Args.ProfileID, story.Args.Timestamp, story.Args.Text)
+}
+
+// markSeen adds key to the seen stories and reports if it was not seen before.
+// The oldest keys are forgotten after seenStories.
+func (m *Meerkat) markSeen(key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.seen[key] {
+		return false
+	}
+	m.seen[key] = true
+	m.seenOrder = append(m.seenOrder, key)
+	if len(m.seenOrder) > seenStories {
+		delete(m.seen, m.seenOrder[0])
+		m.seenOrder = m.seenOrder[1:]
+	}
+	return true
+}
+
+// checkActivity reports stories of the following activity feed
+// of the current account about targets. Each story is reported once,
+// even if it is in the feed of several accounts or for several targets.
+func (m *Meerkat) checkActivity() error {
+	m.logger.Printf("Sending request to get following activities of %s", m.account.Username)
+
+	// stories of the pages read before an error are reported too,
+	// they are already in the seen set.
+	stories, err := m.newStories()
+
+	// oldest first, pages are newest first
+	for i := len(stories) - 1; i >= 0; i-- {
+		story := stories[i]
+		action := parseAction(story)
+		if !m.watchedAction(action) {
+			continue
+		}
+
+		reported := make(map[int64]bool)
+		for _, involved := range action.involved() {
+			user, ok := m.targetUser(involved.ID)
+			if !ok || reported[involved.ID] {
+				continue
+			}
+			reported[involved.ID] = true
+
+			m.send(&Event{
+				Kind:      EventActivity,
+				UserID:    involved.ID,
+				Username:  user.Username,
+				Timestamp: time.Unix(int64(story.Args.Timestamp), 0),
+				StoryID:   story.Pk,
+				Story:     story.Args.Text,
+				Action:    action,
+			})
+		}
+	}
+	return err
+}
+
+// newStories pages the following activity feed backwards until it reaches
+// a seen story, so stories which scrolled off since the last check are not
+// missed. It stops after activitypages pages, or when the request budget is spent.
+// On the first run there is no seen story, and only the first page is read.
+func (m *Meerkat) newStories() ([]followingStory, error) {
+	m.mu.Lock()
+	backfill := len(m.seenOrder) > 0
+	m.mu.Unlock()
+
+	stories := []followingStory{}
+	maxID := 0
+	for page := 1; ; page++ {
+		resp, err := m.instagram.GetFollowingRecentActivityPage(maxID)
+		if err != nil {
+			return stories, err
+		}
+
+		reached := false
+		for _, s := range resp.Stories {
+			story := followingStory(s)
+			if !m.markSeen(storyKey(story)) {
+				reached = true
+				continue
+			}
+			stories = append(stories, story)
+		}
+
+		if reached || !backfill || !resp.AutoLoadMoreEnabled || resp.NextMaxID == 0 {
+			return stories, nil
+		}
+		if page >= m.ActivityPages {
+			m.logger.Printf("Activities of %s have more than %d pages, older ones are skipped", m.account.Username, m.ActivityPages)
+			return stories, nil
+		}
+		if !m.spend(1) {
+			return stories, nil
+		}
+		maxID = resp.NextMaxID
+	}
+}
@@ -0,0 +1,290 @@
+package meerkat
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// recentEvents is the number of events kept for /events.
+const recentEvents = 100
+
+// targetStatus is a target in /targets.
+type targetStatus struct {
+	Username    string          `json:"username"`
+	UserID      int64           `json:"user_id,omitempty"`
+	Snapshot    *targetSnapshot `json:"snapshot,omitempty"`
+	Priority    int             `json:"priority"`
+	NextCheck   time.Time       `json:"next_check"`
+	Failures    int             `json:"failures"`
+	LastError   string          `json:"last_error,omitempty"`
+	Unreachable bool            `json:"unreachable"`
+}
+
+// targetSnapshot is the public part of the snapshot of a target,
+// following lists, comments and engagement are left out as they are large.
+type targetSnapshot struct {
+	Username      string `json:"username"`
+	FullName      string `json:"full_name,omitempty"`
+	Biography     string `json:"biography,omitempty"`
+	ExternalURL   string `json:"external_url,omitempty"`
+	ProfilePicURL string `json:"profile_pic_url,omitempty"`
+	Followers     int    `json:"followers"`
+	Following     int    `json:"following"`
+	Posts         int    `json:"posts"`
+	Tags          int    `json:"tags"`
+	Private       bool   `json:"private"`
+	Verified      bool   `json:"verified"`
+	Business      bool   `json:"business"`
+	// LastPostAt and LastStoryAt are unix times, 0 when they are unknown.
+	LastPostAt  int64 `json:"last_post_at,omitempty"`
+	LastStoryAt int64 `json:"last_story_at,omitempty"`
+}
+
+func newTargetSnapshot(user User) *targetSnapshot {
+	return &targetSnapshot{
+		Username:      user.Username,
+		FullName:      user.FullName,
+		Biography:     user.Bio,
+		ExternalURL:   user.ExternalURL,
+		ProfilePicURL: user.ProfilePicURL,
+		Followers:     user.Followers,
+		Following:     user.Following,
+		Posts:         user.Posts,
+		Tags:          user.Tags,
+		Private:       user.Private,
+		Verified:      user.Verified,
+		Business:      user.Business,
+		LastPostAt:    user.LastPostAt,
+		LastStoryAt:   user.LastStoryAt,
+	}
+}
+
+// recordEvent keeps event for /events.
+func (m *Meerkat) recordEvent(event *Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, event)
+	if len(m.events) > recentEvents {
+		m.events = m.events[len(m.events)-recentEvents:]
+	}
+}
+
+// pollNow makes username, or all targets and following activities
+// when it is empty, due on the next sleeptime slot.
+func (m *Meerkat) pollNow(username string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	targets := m.TargetUsers
+	if username != "" {
+		if !m.isTarget(username) {
+			return fmt.Errorf("%s is not a target", username)
+		}
+		targets = []string{username}
+	} else {
+		m.pollActivity = true
+	}
+
+	for _, target := range targets {
+		m.schedule[target] = time.Time{}
+		if h, ok := m.health[target]; ok {
+			h.NextCheck = time.Time{}
+		}
+	}
+	return nil
+}
+
+// runAPI serves the HTTP status and control API on httpaddr until meerkat stops.
+func (m *Meerkat) runAPI() {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", m.handleHealthz)
+	mux.HandleFunc("/targets", m.authorized(m.handleTargets))
+	mux.HandleFunc("/targets/add", m.authorized(m.handleAddTarget))
+	mux.HandleFunc("/targets/remove", m.authorized(m.handleRemoveTarget))
+	mux.HandleFunc("/events", m.authorized(m.handleEvents))
+	mux.HandleFunc("/poll", m.authorized(m.handlePoll))
+
+	server := &http.Server{Addr: m.HTTPAddr, Handler: mux}
+	go func() {
+		<-m.stop
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	m.logger.Println("HTTP API is listening on", m.HTTPAddr)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		m.logger.Println("Error", err)
+	}
+}
+
+// authorized requires httptoken as a bearer token, when it is set.
+func (m *Meerkat) authorized(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if m.HTTPToken != "" {
+			token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(token), []byte(m.HTTPToken)) != 1 {
+				writeError(w, http.StatusUnauthorized, "invalid token")
+				return
+			}
+		}
+		handler(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// requestUsername reads username of POST requests,
+// from a JSON body or from form and query values.
+func requestUsername(r *http.Request) (string, error) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		body := struct {
+			Username string `json:"username"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return "", err
+		}
+		return strings.TrimPrefix(strings.TrimSpace(body.Username), "@"), nil
+	}
+	return strings.TrimPrefix(strings.TrimSpace(r.FormValue("username")), "@"), nil
+}
+
+func (m *Meerkat) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	available := len(m.availableAccounts())
+
+	m.mu.Lock()
+	status := map[string]interface{}{
+		"status":   "ok",
+		"paused":   m.paused,
+		"targets":  len(m.TargetUsers),
+		"accounts": available,
+	}
+	if !m.lastTick.IsZero() {
+		status["last_check"] = m.lastTick
+	}
+	m.mu.Unlock()
+
+	code := http.StatusOK
+	if available == 0 {
+		status["status"] = "no account available"
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, status)
+}
+
+func (m *Meerkat) handleTargets(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+
+	m.mu.Lock()
+	targets := []targetStatus{}
+	for _, username := range m.TargetUsers {
+		t := targetStatus{
+			Username:  username,
+			Priority:  m.TargetOptions[username].Priority,
+			NextCheck: m.schedule[username],
+		}
+		for id, user := range m.targetUsers {
+			if user.Username == username {
+				t.UserID, t.Snapshot = id, newTargetSnapshot(user)
+			}
+		}
+		if h, ok := m.health[username]; ok {
+			if h.NextCheck.After(t.NextCheck) {
+				t.NextCheck = h.NextCheck
+			}
+			t.Failures, t.LastError, t.Unreachable = h.Failures, h.LastError, h.Unreachable
+		}
+		targets = append(targets, t)
+	}
+	m.mu.Unlock()
+
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Username < targets[j].Username })
+	writeJSON(w, http.StatusOK, targets)
+}
+
+func (m *Meerkat) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+
+	limit := recentEvents
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "limit should be a positive number")
+			return
+		}
+		limit = n
+	}
+
+	m.mu.Lock()
+	events := m.events
+	if len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+	events = append([]*Event{}, events...)
+	m.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, events)
+}
+
+func (m *Meerkat) handleAddTarget(w http.ResponseWriter, r *http.Request) {
+	m.handleTargetChange(w, r, m.addTarget)
+}
+
+func (m *Meerkat) handleRemoveTarget(w http.ResponseWriter, r *http.Request) {
+	m.handleTargetChange(w, r, m.removeTarget)
+}
+
+func (m *Meerkat) handleTargetChange(w http.ResponseWriter, r *http.Request, change func(string) error) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	username, err := requestUsername(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := change(username); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"username": username})
+}
+
+func (m *Meerkat) handlePoll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	username, err := requestUsername(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := m.pollNow(username); err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "scheduled"})
+}
@@ -0,0 +1,59 @@
+package meerkat
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleTargetsSnapshot(t *testing.T) {
+	m := testMeerkat(t, testConfig)
+	m.targetUsers[1] = User{
+		Username:      "bob",
+		Bio:           "hello",
+		Followers:     10,
+		FollowerList:  map[int64]string{2: "alice"},
+		FollowingList: map[int64]string{2: "alice"},
+		Comments:      map[string]map[int64]Comment{"1_1": {3: {Text: "hi"}}},
+		Engagement:    map[string]*PostEngagement{"1_1": {Likes: 10}},
+	}
+
+	w := httptest.NewRecorder()
+	m.handleTargets(w, httptest.NewRequest(http.MethodGet, "/targets", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status code %d", w.Code)
+	}
+
+	targets := []map[string]json.RawMessage{}
+	if err := json.Unmarshal(w.Body.Bytes(), &targets); err != nil {
+		t.Fatal(err)
+	}
+	for _, target := range targets {
+		if string(target["username"]) != `"bob"` {
+			continue
+		}
+		snapshot := map[string]json.RawMessage{}
+		if err := json.Unmarshal(target["snapshot"], &snapshot); err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]string{"username": `"bob"`, "biography": `"hello"`, "followers": "10"}
+		for key, value := range want {
+			if string(snapshot[key]) != value {
+				t.Errorf("%s is %s, want %s", key, snapshot[key], value)
+			}
+		}
+		for key := range snapshot {
+			if key[0] >= 'A' && key[0] <= 'Z' {
+				t.Errorf("internal field %s is in the snapshot", key)
+			}
+		}
+		for _, key := range []string{"follower_list", "FollowerList", "comments", "engagement"} {
+			if _, ok := snapshot[key]; ok {
+				t.Errorf("%s is in the snapshot", key)
+			}
+		}
+		return
+	}
+	t.Errorf("bob is not in %s", w.Body.String())
+}
@@ -0,0 +1,97 @@
+package meerkat
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const archiveTimeout = 5 * time.Minute
+
+var (
+	archiveMu      sync.Mutex
+	archiveClients = map[string]*http.Client{}
+)
+
+// archiveClient returns the download client of proxyURL, downloads go out
+// through the same proxy as the requests of the account.
+func archiveClient(proxyURL string) (*http.Client, error) {
+	archiveMu.Lock()
+	defer archiveMu.Unlock()
+
+	if client, ok := archiveClients[proxyURL]; ok {
+		return client, nil
+	}
+	client := &http.Client{Timeout: archiveTimeout}
+	if proxyURL != "" {
+		u, err := parseProxy(proxyURL)
+		if err != nil {
+			return nil, err
+		}
+		client.Transport = &http.Transport{Proxy: http.ProxyURL(u)}
+	}
+	archiveClients[proxyURL] = client
+	return client, nil
+}
+
+// archive downloads fileURL into archivedir/username/name through the proxy
+// of the current account, the extension is taken from the url.
+// Existing files are not downloaded again.
+func (m *Meerkat) archive(username, name, fileURL string) (string, error) {
+	u, err := url.Parse(fileURL)
+	if err != nil {
+		return "", err
+	}
+	ext := path.Ext(u.Path)
+	if ext == "" {
+		ext = ".jpg"
+	}
+
+	dir := filepath.Join(m.ArchiveDir, username)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	file := filepath.Join(dir, name+ext)
+	if _, err := os.Stat(file); err == nil {
+		return file, nil
+	}
+
+	proxyURL := ""
+	if m.account != nil {
+		proxyURL = m.proxyURL(m.account)
+	}
+	client, err := archiveClient(proxyURL)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Get(fileURL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download %s, status code %d", name, resp.StatusCode)
+	}
+
+	// download into a temporary file, so a broken download is not taken as archived
+	tmpFile := file + ".tmp"
+	output, err := os.Create(tmpFile)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(output, resp.Body)
+	if closeErr := output.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpFile)
+		return "", err
+	}
+	return file, os.Rename(tmpFile, file)
+}
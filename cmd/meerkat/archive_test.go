@@ -0,0 +1,68 @@
+package meerkat
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestArchiveThroughProxy(t *testing.T) {
+	hosts := []string{}
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts = append(hosts, r.URL.Host)
+		fmt.Fprint(w, "image")
+	}))
+	defer proxy.Close()
+
+	m := testMeerkat(t, testConfig)
+	m.ArchiveDir = filepath.Dir(m.configFile)
+	m.account = &account{Account: Account{Username: "watcher", Proxy: proxy.URL}}
+
+	file, err := m.archive("bob", "post_1", "http://cdn.example/p/1.png?x=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 1 || hosts[0] != "cdn.example" {
+		t.Fatalf("proxy got requests for %v", hosts)
+	}
+	if file != filepath.Join(m.ArchiveDir, "bob", "post_1.png") {
+		t.Errorf("archived as %s", file)
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil || string(data) != "image" {
+		t.Errorf("archived %q, %v", data, err)
+	}
+
+	// archived files are not downloaded again
+	if _, err := m.archive("bob", "post_1", "http://cdn.example/p/1.png"); err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 1 {
+		t.Errorf("archived file is downloaded again")
+	}
+}
+
+func TestArchiveWithoutProxy(t *testing.T) {
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/1.jpg" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "image")
+	}))
+	defer cdn.Close()
+
+	m := testMeerkat(t, testConfig)
+	m.ArchiveDir = filepath.Dir(m.configFile)
+	m.account = &account{}
+
+	if _, err := m.archive("bob", "post_1", cdn.URL+"/1.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.archive("bob", "post_2", cdn.URL+"/2.jpg"); err == nil {
+		t.Error("failed download is archived")
+	}
+}
@@ -0,0 +1,167 @@
+package meerkat
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const botHelp = `Commands :
+/add username - watch a new target
+/remove username - stop watching a target
+/list - list of targets
+/status - status of meerkat
+/pause - pause the watcher
+/resume - resume the watcher
+/snapshot username - last known information of a target`
+
+type telegramUpdate struct {
+	UpdateID int64 `json:"update_id"`
+	Message  *struct {
+		Text string `json:"text"`
+		Chat struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+	} `json:"message"`
+}
+
+// botAdmins returns chats which are allowed to control meerkat,
+// telegramadmins or telegramuser if it is empty.
+func (m *Meerkat) botAdmins() map[int64]bool {
+	admins := make(map[int64]bool)
+	for _, chat := range m.TelegramAdmins {
+		admins[chat] = true
+	}
+	if len(admins) == 0 && m.TelegramUser != 0 {
+		admins[int64(m.TelegramUser)] = true
+	}
+	return admins
+}
+
+// runBot long polls Telegram for commands until meerkat stops.
+func (m *Meerkat) runBot() {
+	client := newTelegramClient(m.TelegramAPIURL, m.TelegramToken)
+	admins := m.botAdmins()
+	offset := int64(0)
+
+	m.logger.Println("Telegram bot is waiting for commands")
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		default:
+		}
+
+		updates := []telegramUpdate{}
+		err := client.call("getUpdates", map[string]interface{}{
+			"offset":          offset,
+			"timeout":         50,
+			"allowed_updates": []string{"message"},
+		}, &updates)
+		if err != nil {
+			m.logger.Println("Error", err)
+			time.Sleep(10 * time.Second)
+			continue
+		}
+
+		for _, update := range updates {
+			offset = update.UpdateID + 1
+			if update.Message == nil {
+				continue
+			}
+
+			chat := update.Message.Chat.ID
+			if !admins[chat] {
+				m.logger.Printf("Telegram bot ignored a command from unauthorized chat %d", chat)
+				continue
+			}
+
+			reply := m.botCommand(update.Message.Text)
+			if err := client.sendMessage(chat, reply, ""); err != nil {
+				m.logger.Println("Error", err)
+			}
+		}
+	}
+}
+
+// botCommand runs a command and returns its reply.
+func (m *Meerkat) botCommand(text string) string {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return botHelp
+	}
+
+	// commands in groups may be in /command@botname form
+	command := strings.SplitN(fields[0], "@", 2)[0]
+	arg := ""
+	if len(fields) > 1 {
+		arg = strings.TrimPrefix(fields[1], "@")
+	}
+
+	switch command {
+	case "/add":
+		if err := m.addTarget(arg); err != nil {
+			return err.Error()
+		}
+		return fmt.Sprintf("%s added to targets.", arg)
+	case "/remove":
+		if err := m.removeTarget(arg); err != nil {
+			return err.Error()
+		}
+		return fmt.Sprintf("%s removed from targets.", arg)
+	case "/list":
+		targets := m.targets()
+		if len(targets) == 0 {
+			return "There is no target."
+		}
+		sort.Strings(targets)
+		return "Targets :\n" + strings.Join(targets, "\n")
+	case "/status":
+		return m.status()
+	case "/pause":
+		m.setPaused(true)
+		return "Watcher paused."
+	case "/resume":
+		m.setPaused(false)
+		return "Watcher resumed."
+	case "/snapshot":
+		id, user, ok := m.targetByUsername(arg)
+		if !ok {
+			return fmt.Sprintf("There is no snapshot of %s yet.", arg)
+		}
+		return fmt.Sprintf("%s (%d)\nBiography : %s\nFollowers : %d\nFollowing : %d\nPosts : %d\nTags : %d\n%s",
+			user.Username, id, user.Bio, user.Followers, user.Following, user.Posts, user.Tags, profileURL(user.Username))
+	}
+	return botHelp
+}
+
+func (m *Meerkat) status() string {
+	accounts := m.accountsStatus()
+	if len(m.proxies) > 0 {
+		accounts = append(accounts, "Proxies : "+m.proxiesStatus())
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state := "running"
+	if m.paused {
+		state = "paused"
+	}
+	lastTick := "never"
+	if !m.lastTick.IsZero() {
+		lastTick = m.lastTick.Format("2006-01-02 15:04:05 MST")
+	}
+	unreachable := []string{}
+	for username, h := range m.health {
+		if h.Unreachable {
+			unreachable = append(unreachable, username)
+		}
+	}
+	sort.Strings(unreachable)
+
+	return fmt.Sprintf("Watcher is %s\nTargets : %d\nSnapshots : %d\nUnreachable : %s\nLast check : %s\nAccounts :\n%s",
+		state, len(m.TargetUsers), len(m.targetUsers), strings.Join(unreachable, ", "), lastTick, strings.Join(accounts, "\n"))
+}
@@ -1,18 +1,16 @@
 package meerkat
 
 import (
-	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
-	"net/http"
 	"os"
-	"strconv"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/ahmdrz/goinsta"
+	"github.com/ahmdrz/goinsta/response"
 	"gopkg.in/yaml.v2"
 )
 
@@ -26,18 +24,94 @@ type Meerkat struct {
 	SleepTime   int
 	Username    string
 	Password    string
+	Accounts    []Account
 	TargetUsers []string
 	OutputType  string
 
-	TelegramToken string
-	TelegramUser  int
-
-	instagram     *goinsta.Instagram
-	logger        *log.Logger
-	lastTimeStamp int
-	targetUsers   map[int64]User
-	login         bool
-	loggerFile    *os.File
+	Proxy         string
+	Proxies       []string
+	ProxyRotation int
+
+	TelegramToken     string
+	TelegramUser      int
+	TelegramChats     []int64
+	TelegramRoutes    []TelegramRoute
+	TelegramParseMode string
+	TelegramAPIURL    string
+	TelegramCommands  bool
+	TelegramAdmins    []int64
+
+	Webhook WebhookConfig
+
+	HTTPAddr  string
+	HTTPToken string
+
+	StateFile   string
+	SessionFile string
+
+	Timezone  string
+	Templates map[string]map[string]string
+
+	RequestsPerHour int
+	TargetOptions   map[string]TargetOptions
+
+	ActivityTypes      []string
+	ActivityPages      int
+	FollowListInterval int
+	WatchPosts         bool
+	WatchStories       bool
+	WatchComments      int
+	CommentsByTargets  bool
+	CommentKeywords    []string
+	WatchEngagement    int
+	LikeThresholds     []int
+	CommentThresholds  []int
+	ArchiveDir         string
+
+	instagram   *goinsta.Instagram
+	logger      *log.Logger
+	targetUsers map[int64]User
+	loggerFile  *os.File
+	senders     []Sender
+	configFile  string
+	passphrase  string
+
+	// accounts is the pool of watcher accounts,
+	// account is the one which instagram belongs to.
+	accounts []*account
+	account  *account
+
+	proxies      []*proxy
+	nextRotation time.Time
+
+	// reelsTray is latest story time of users in stories tray
+	reelsTray map[int64]int64
+	// health and next check of targets by username, guarded by mu
+	health   map[string]*targetHealth
+	schedule map[string]time.Time
+
+	nextActivity  time.Time
+	requests      []time.Time
+	budgetWarning int
+	slotsWarning  int
+
+	mu       sync.Mutex
+	paused   bool
+	lastTick time.Time
+	stop     chan struct{}
+
+	// seen and seenOrder are the reported activity stories
+	seen      map[string]bool
+	seenOrder []string
+
+	// events are the recent events of the HTTP API,
+	// pollActivity makes following activities due on the next slot.
+	events       []*Event
+	pollActivity bool
+
+	// configMu serializes rewrites of the configuration file,
+	// which come from the bot, the HTTP API and the watcher.
+	configMu sync.Mutex
 }
 
 type User struct {
@@ -47,6 +121,39 @@ type User struct {
 	Following int
 	Posts     int
 	Tags      int
+
+	FullName      string
+	ExternalURL   string
+	ProfilePicID  string
+	ProfilePicURL string
+	Private       bool
+	Verified      bool
+	Business      bool
+
+	// FollowerList and FollowingList map user IDs to usernames,
+	// they are fetched every followlistinterval minutes.
+	// FollowerFetch and FollowingFetch are the lists being fetched.
+	FollowerList   map[int64]string
+	FollowingList  map[int64]string
+	FollowerFetch  *ListFetch
+	FollowingFetch *ListFetch
+	ListsCheckedAt time.Time
+
+	// LastPostAt is the time of the newest known post, used by watchposts.
+	LastPostAt     int64
+	PostsCheckedAt time.Time
+
+	// LastStoryAt is the time of the newest known story item, used by watchstories.
+	LastStoryAt      int64
+	StoriesCheckedAt time.Time
+
+	// RecentPosts are the latest posts, newest first, and Comments are
+	// comments of the watchcomments latest ones by media ID and comment ID.
+	RecentPosts []RecentPost
+	Comments    map[string]map[int64]Comment
+
+	// Engagement of the watchengagement latest posts by media ID.
+	Engagement map[string]*PostEngagement
 }
 
 func (m *Meerkat) parseArgs() error {
@@ -70,6 +177,23 @@ func (m *Meerkat) parseArgs() error {
 
 			os.Exit(0)
 		}
+
+		// meerkat encrypt
+		// meerkat decrypt config.yaml
+		if os.Args[1] == "encrypt" || os.Args[1] == "decrypt" {
+			configFile := "meerkat.yaml"
+			if len(os.Args) > 2 {
+				configFile = os.Args[2]
+			}
+			if err := cryptCommand(os.Args[1], configFile); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			fmt.Println(configFile, os.Args[1]+"ed.")
+
+			os.Exit(0)
+		}
 	}
 
 	configFile := "meerkat.yaml"
@@ -104,6 +228,19 @@ func (m *Meerkat) parseArgs() error {
 	if err != nil {
 		return err
 	}
+	m.configFile = configFile
+
+	if isEncrypted(bytes) {
+		m.passphrase, err = readPassphrase(false)
+		if err != nil {
+			return err
+		}
+		bytes, err = decryptConfig(bytes, m.passphrase)
+		if err != nil {
+			return fmt.Errorf("config file [%s] %s", configFile, err.Error())
+		}
+	}
+
 	err = yaml.Unmarshal(bytes, m)
 	if err != nil {
 		return err
@@ -113,191 +250,150 @@ func (m *Meerkat) parseArgs() error {
 }
 
 func (m *Meerkat) Run(done chan bool) error {
-	m.logger.Println("Logging in to the Instagram")
+	if err := m.loadState(); err != nil {
+		return err
+	}
 
-	m.instagram = goinsta.New(m.Username, m.Password)
-	err := m.instagram.Login()
-	if err != nil {
-		return fmt.Errorf("Instagram error , %s", err.Error())
+	if err := m.loginAccounts(); err != nil {
+		return err
 	}
-	m.login = true
 
-	m.logger.Println("Successfully logged in")
+	m.logger.Printf("Successfully logged in with %d of %d accounts", len(m.availableAccounts()), len(m.accounts))
 
-	select {
-	case <-done:
-		return fmt.Errorf("Signal on meerkat !")
-	default:
-		for _, username := range m.TargetUsers {
-			m.logger.Printf("Getting %s information ", username)
+	m.stop = make(chan struct{})
+	defer close(m.stop)
 
-			user, err := m.instagram.GetUserByUsername(username)
-			if err != nil {
-				return err
-			}
-			m.targetUsers[user.User.ID] = User{
-				Username:  username,
-				Followers: user.User.FollowerCount,
-				Following: user.User.FollowingCount,
-				Bio:       user.User.Biography,
-				Posts:     user.User.MediaCount,
-				Tags:      user.User.UserTagsCount,
-			}
+	if m.TelegramCommands {
+		go m.runBot()
+	}
 
-			m.logger.Printf("User %s-%d information has been retrived successfully.", username, user.User.ID)
-		}
+	if m.HTTPAddr != "" {
+		go m.runAPI()
 	}
 
 	m.logger.Println("Starting watcher ...")
 
-	var failure int = 0
-	var exitErr error
+	// activities wait until every target has been checked once (see runJob)
+	m.nextActivity = time.Now().Add(time.Duration(m.Interval) * time.Second)
+	m.checkBudget()
 
-	// TODO : make select statement better !
-	tick := time.After(time.Duration(m.Interval) * time.Second)
+	slot := time.NewTicker(m.slot())
+	defer slot.Stop()
 
-	for failure != 3 {
+	for {
 		select {
 		case <-done:
 			return fmt.Errorf("Signal on meerkat !")
-		case <-tick:
-			tick = time.After(time.Duration(m.Interval) * time.Second)
-
-			m.logger.Println("Sending request to get following activities")
-
-			resp, err := m.instagram.GetFollowingRecentActivity()
-			if err != nil {
-				m.logger.Println("Error", err)
-				failure++
-				exitErr = err
+		case <-slot.C:
+			if m.isPaused() {
 				continue
 			}
+			m.runJob()
+		}
+	}
+}
 
-			// to find last time stamp
-			maxTimeStamp := int(0)
-			for _, story := range resp.Stories {
-				unixTimeStamp := story.Args.Timestamp
-
-				if unixTimeStamp <= m.lastTimeStamp {
-					continue
-				}
-
-				for _, link := range story.Args.Links {
-					if link.Type == "user" {
-						userID, _ := strconv.ParseInt(link.ID, 10, 64)
-
-						if user, ok := m.targetUsers[userID]; ok {
-							unixTime := time.Unix(int64(unixTimeStamp), 0)
-
-							message := fmt.Sprintf("[%s] [%s] %s\n", user.Username, unixTime.Format("15:04:05"), story.Args.Text)
-
-							// TODO: parse to array of string and search over it.
-							if strings.Contains(m.OutputType, "telegram") {
-								m.sendToTelegram(m.TelegramUser, message)
-							}
-							if strings.Contains(m.OutputType, "logfile") {
-								m.logger.Println(message)
-							}
-						}
-					}
-				}
-
-				if unixTimeStamp > maxTimeStamp {
-					maxTimeStamp = unixTimeStamp
-				}
-			}
-			if maxTimeStamp != 0 {
-				m.lastTimeStamp = maxTimeStamp
-			}
+// checkUser fetches information of username and reports the differences
+// with the last known snapshot. The first snapshot of a user is stored
+// without any report. It returns the current username of the target,
+// which differs from username after a rename.
+func (m *Meerkat) checkUser(username string) (string, error) {
+	m.logger.Printf("Getting %s information ", username)
+
+	// targets are followed by their ID once it is known,
+	// so a renamed target is still found.
+	var user response.GetUsernameResponse
+	var err error
+	userID, tmpUser, ok := m.targetByUsername(username)
+	if ok {
+		user, err = m.instagram.GetUserByID(userID)
+	} else {
+		user, err = m.instagram.GetUserByUsername(username)
+	}
+	if err != nil {
+		return username, err
+	}
 
-			failure = 0
-
-			for _, username := range m.TargetUsers {
-				m.logger.Printf("Getting %s information ", username)
-
-				user, err := m.instagram.GetUserByUsername(username)
-				if err != nil {
-					m.logger.Println("Error", err)
-					failure++
-					exitErr = err
-					continue
-				}
-				tmpUser := m.targetUsers[user.User.ID]
-				currentTime := time.Now().Format("15:04:05")
-
-				message := fmt.Sprintf("[%s] [%s] : \n", username, currentTime)
-				hasMessage := false
-
-				if user.User.Biography != tmpUser.Bio {
-					message += fmt.Sprintf("User %s biography changed to %s\n", username, user.User.Biography)
-					hasMessage = true
-
-					tmpUser.Bio = user.User.Biography
-				}
-				if user.User.FollowerCount != tmpUser.Followers {
-					message += fmt.Sprintf("User %s followers changed from %d to %d\n", username, tmpUser.Followers, user.User.FollowerCount)
-					hasMessage = true
-
-					tmpUser.Followers = user.User.FollowerCount
-				}
-				if user.User.FollowingCount != tmpUser.Following {
-					message += fmt.Sprintf("User %s following changed from %d to %d\n", username, tmpUser.Following, user.User.FollowingCount)
-					hasMessage = true
-
-					tmpUser.Following = user.User.FollowingCount
-				}
-				if user.User.FollowingCount != tmpUser.Following {
-					message += fmt.Sprintf("User %s posts changed from %d to %d\n", username, tmpUser.Posts, user.User.MediaCount)
-					hasMessage = true
-
-					tmpUser.Posts = user.User.MediaCount
-				}
-				if user.User.UserTagsCount != tmpUser.Tags {
-					message += fmt.Sprintf("User %s tags changed from %d to %d\n", username, tmpUser.Tags, user.User.UserTagsCount)
-					hasMessage = true
-
-					tmpUser.Tags = user.User.UserTagsCount
-				}
-
-				if hasMessage {
-					m.targetUsers[user.User.ID] = tmpUser
-
-					// TODO: parse to array of string and search over it.
-					if strings.Contains(m.OutputType, "telegram") {
-						m.sendToTelegram(m.TelegramUser, message)
-					}
-					if strings.Contains(m.OutputType, "logfile") {
-						m.logger.Println(message)
-					}
-				}
-
-				m.logger.Printf("User %s information has been updated successfully.", username)
-
-				time.Sleep(time.Duration(m.SleepTime) * time.Second)
-			}
+	if ok && user.User.Username != "" && user.User.Username != username {
+		if err := m.renameTarget(username, user.User.Username); err != nil {
+			m.logger.Println("Error", err)
 		}
+		m.send(&Event{
+			Kind:      EventRename,
+			UserID:    userID,
+			Username:  user.User.Username,
+			Field:     "username",
+			OldValue:  username,
+			NewValue:  user.User.Username,
+			Timestamp: time.Now(),
+		})
+		username = user.User.Username
+		tmpUser.Username = username
 	}
 
-	if failure == 3 {
-		return exitErr
+	postsChanged := ok && tmpUser.Posts != user.User.MediaCount
+	if !ok {
+		tmpUser = User{Username: username}
 	}
 
-	return nil
+	m.checkProfile(user, &tmpUser, ok)
+
+	if !ok {
+		m.logger.Printf("User %s-%d information has been retrived successfully.", username, user.User.ID)
+	} else {
+		m.logger.Printf("User %s information has been updated successfully.", username)
+	}
+
+	if err := m.checkFollowLists(user.User.ID, &tmpUser); err != nil {
+		m.logger.Printf("Error, following lists of %s, %s", username, err.Error())
+	}
+
+	if err := m.checkPosts(user.User.ID, &tmpUser, postsChanged); err != nil {
+		m.logger.Printf("Error, posts of %s, %s", username, err.Error())
+	}
+
+	if err := m.checkStories(user.User.ID, &tmpUser); err != nil {
+		m.logger.Printf("Error, stories of %s, %s", username, err.Error())
+	}
+
+	if err := m.checkEngagement(user.User.ID, &tmpUser); err != nil {
+		m.logger.Printf("Error, engagement of %s, %s", username, err.Error())
+	}
+
+	if err := m.checkComments(user.User.ID, &tmpUser, postsChanged); err != nil {
+		m.logger.Printf("Error, comments of %s, %s", username, err.Error())
+	}
+
+	m.setTargetUser(user.User.ID, tmpUser)
+	return username, nil
 }
 
+// Logout keeps the Instagram sessions of accounts for the next run
+// instead of logging out, and closes the outputs and the log output file.
 func (m *Meerkat) Logout() error {
-	if m.login {
-		return m.instagram.Logout()
+	err := m.closeSenders()
+	for _, acc := range m.accounts {
+		if acc.instagram == nil {
+			continue
+		}
+		if exportErr := exportSession(acc); err == nil {
+			err = exportErr
+		}
 	}
 	if m.loggerFile != nil {
-		return m.loggerFile.Close()
+		if closeErr := m.loggerFile.Close(); err == nil {
+			err = closeErr
+		}
 	}
-	return nil
+	return err
 }
 
 func New() (*Meerkat, error) {
 	m := &Meerkat{}
 	m.targetUsers = make(map[int64]User)
+	m.health = make(map[string]*targetHealth)
+	m.schedule = make(map[string]time.Time)
+	m.seen = make(map[string]bool)
 	m.loggerFile = nil
 
 	if err := m.parseArgs(); err != nil {
@@ -308,49 +404,45 @@ func New() (*Meerkat, error) {
 		return nil, fmt.Errorf("There is no targetusers in yaml config file")
 	}
 
-	if m.OutputType == "" {
-		return nil, fmt.Errorf("Fill outputtype with ['logfile', 'telegram']")
+	if m.TelegramCommands && m.TelegramToken == "" {
+		return nil, fmt.Errorf("Fill telegramtoken to use telegramcommands")
 	}
 
-	if m.Interval < 10 {
-		log.Println("Interval is low, try more than 10 seconds.")
+	if err := checkActionTypes(m.ActivityTypes); err != nil {
+		return nil, err
 	}
 
-	if m.SleepTime < 10 {
-		log.Println("SleepTime is low, try more than 10 seconds.")
+	if err := m.loadSenders(); err != nil {
+		return nil, err
 	}
 
-	return m, nil
-}
+	if m.StateFile == "" {
+		m.StateFile = "meerkat.state"
+	}
 
-func (m *Meerkat) sendToTelegram(to int, message string) error {
-	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage?chat_id=%d&text=%s", m.TelegramToken, to, message)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return err
+	if m.ActivityPages < 1 {
+		m.ActivityPages = 5
 	}
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
+
+	if m.SessionFile == "" {
+		m.SessionFile = "meerkat.session"
 	}
 
-	bytes, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return err
+	if err := m.loadProxies(); err != nil {
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	var output struct {
-		Ok          bool   `json:"ok"`
-		Description string `json:"description"`
+	if err := m.loadAccounts(); err != nil {
+		return nil, err
 	}
 
-	json.Unmarshal(bytes, &output)
+	if m.Interval < 10 {
+		log.Println("Interval is low, try more than 10 seconds.")
+	}
 
-	if !output.Ok {
-		return fmt.Errorf("Telegram bot %s", output.Description)
+	if m.SleepTime < 10 {
+		log.Println("SleepTime is low, try more than 10 seconds.")
 	}
 
-	return nil
+	return m, nil
 }
@@ -0,0 +1,161 @@
+package meerkat
+
+import (
+	"strings"
+	"time"
+
+	"github.com/ahmdrz/goinsta/response"
+)
+
+// RecentPost is one of the latest posts of a target.
+type RecentPost struct {
+	ID      string
+	Code    string
+	TakenAt int64
+}
+
+// Comment is a known comment on a recent post of a target.
+type Comment struct {
+	UserID    int64
+	Username  string
+	Text      string
+	CreatedAt int64
+}
+
+func recentPosts(items []response.Item) []RecentPost {
+	posts := []RecentPost{}
+	for _, item := range items {
+		posts = append(posts, RecentPost{ID: item.ID, Code: item.Code, TakenAt: item.TakenAt})
+	}
+	return posts
+}
+
+// watchedComment reports if a comment passes commentsbytargets and commentkeywords,
+// all comments pass when neither of them is set.
+func (m *Meerkat) watchedComment(ownerID int64, comment Comment) bool {
+	if !m.CommentsByTargets && len(m.CommentKeywords) == 0 {
+		return true
+	}
+	if m.CommentsByTargets && comment.UserID != ownerID {
+		if _, ok := m.targetUser(comment.UserID); ok {
+			return true
+		}
+	}
+	text := strings.ToLower(comment.Text)
+	for _, keyword := range m.CommentKeywords {
+		if strings.Contains(text, strings.ToLower(keyword)) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkComments reports new and removed comments on the watchcomments latest
+// posts of a target. Comments of a post seen for the first time are only stored.
+// Only the latest page of comments is read, so a known comment older than
+// that page is kept without checking it.
+func (m *Meerkat) checkComments(userID int64, user *User, postsChanged bool) error {
+	if m.WatchComments <= 0 {
+		return nil
+	}
+
+	// checkPosts and checkEngagement refresh recent posts when they are enabled
+	if user.RecentPosts == nil || (postsChanged && !m.WatchPosts && m.WatchEngagement <= 0) {
+		feed, err := m.instagram.LatestUserFeed(userID)
+		if err != nil {
+			return err
+		}
+		user.RecentPosts = recentPosts(feed.Items)
+	}
+
+	posts := user.RecentPosts
+	if len(posts) > m.WatchComments {
+		posts = posts[:m.WatchComments]
+	}
+
+	known := user.Comments
+	user.Comments = make(map[string]map[int64]Comment)
+	for _, post := range posts {
+		resp, err := m.instagram.MediaComments(post.ID, "")
+		if err != nil {
+			// keep comments of the post for the next check
+			if comments, ok := known[post.ID]; ok {
+				user.Comments[post.ID] = comments
+			}
+			m.logger.Printf("Error, comments of %s post %s, %s", user.Username, post.Code, err.Error())
+			continue
+		}
+
+		comments := make(map[int64]Comment)
+		oldest := int64(0)
+		for _, c := range resp.Comments {
+			comments[c.Pk] = Comment{
+				UserID:    c.UserID,
+				Username:  c.User.Username,
+				Text:      c.Text,
+				CreatedAt: c.CreatedAt,
+			}
+			if oldest == 0 || c.CreatedAt < oldest {
+				oldest = c.CreatedAt
+			}
+		}
+
+		previous, ok := known[post.ID]
+		user.Comments[post.ID] = comments
+		if !ok {
+			continue
+		}
+
+		media := &Media{
+			ID:      post.ID,
+			Code:    post.Code,
+			URL:     postURL(post.Code),
+			TakenAt: time.Unix(post.TakenAt, 0),
+		}
+
+		for id, comment := range comments {
+			if _, ok := previous[id]; ok || !m.watchedComment(userID, comment) {
+				continue
+			}
+			m.send(&Event{
+				Kind:          EventComment,
+				UserID:        userID,
+				Username:      user.Username,
+				OtherUserID:   comment.UserID,
+				OtherUsername: comment.Username,
+				Field:         "comment",
+				NewValue:      comment.Text,
+				CommentID:     id,
+				Media:         media,
+				Timestamp:     time.Unix(comment.CreatedAt, 0),
+			})
+		}
+
+		for id, comment := range previous {
+			if _, ok := comments[id]; ok {
+				continue
+			}
+			if resp.NextMaxID != "" && comment.CreatedAt < oldest {
+				// older than the latest page
+				comments[id] = comment
+				continue
+			}
+			if !m.watchedComment(userID, comment) {
+				continue
+			}
+			m.send(&Event{
+				Kind:          EventCommentRemoved,
+				UserID:        userID,
+				Username:      user.Username,
+				OtherUserID:   comment.UserID,
+				OtherUsername: comment.Username,
+				Field:         "comment",
+				OldValue:      comment.Text,
+				CommentID:     id,
+				Media:         media,
+				Timestamp:     time.Now(),
+			})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package meerkat
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// saveConfig writes the current targets back to the configuration file.
+// Only the targetusers block is rewritten, so comments and other options
+// are kept as they are. Encrypted configurations stay encrypted.
+func (m *Meerkat) saveConfig() error {
+	return m.editConfig(m.replaceTargets)
+}
+
+// editConfig rewrites the lines of the configuration file with edit.
+// The file is replaced at once, so a crash never leaves it half written.
+func (m *Meerkat) editConfig(edit func(lines []string) []string) error {
+	if m.configFile == "" {
+		return nil
+	}
+
+	m.configMu.Lock()
+	defer m.configMu.Unlock()
+
+	data, err := ioutil.ReadFile(m.configFile)
+	if err != nil {
+		return err
+	}
+	if isEncrypted(data) {
+		data, err = decryptConfig(data, m.passphrase)
+		if err != nil {
+			return err
+		}
+	}
+
+	data = []byte(strings.Join(edit(strings.Split(string(data), "\n")), "\n"))
+
+	// never write a configuration which meerkat can not read
+	check := struct{ TargetUsers []string }{}
+	if err := yaml.Unmarshal(data, &check); err != nil {
+		return fmt.Errorf("could not update %s, %s", m.configFile, err.Error())
+	}
+
+	if m.passphrase != "" {
+		data, err = encryptConfig(data, m.passphrase)
+		if err != nil {
+			return err
+		}
+	}
+	return writeFileAtomic(m.configFile, data)
+}
+
+// writeFileAtomic writes data to a temporary file next to filename
+// and renames it to filename.
+func writeFileAtomic(filename string, data []byte) error {
+	file, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), filename)
+}
+
+// replaceTargets replaces the targetusers block with the current targets,
+// comments between the old items are dropped with them.
+func (m *Meerkat) replaceTargets(lines []string) []string {
+	block := []string{"targetusers: "}
+	for _, target := range m.targets() {
+		block = append(block, fmt.Sprintf("  - %q", target))
+	}
+
+	output := make([]string, 0, len(lines)+len(block))
+	replaced := false
+	for i := 0; i < len(lines); i++ {
+		if replaced || !strings.HasPrefix(lines[i], "targetusers:") {
+			output = append(output, lines[i])
+			continue
+		}
+		// skip the old list
+		i = blockEnd(lines, i) - 1
+		output = append(output, block...)
+		replaced = true
+	}
+	if !replaced {
+		output = append(output, block...)
+	}
+	return output
+}
+
+// renameInBlock replaces oldName with newName as a whole username
+// in the value of the top-level key.
+func renameInBlock(lines []string, key, oldName, newName string) []string {
+	word := regexp.MustCompile(`(^|[^A-Za-z0-9._])` + regexp.QuoteMeta(oldName) + `($|[^A-Za-z0-9._])`)
+	rename := func(text string) string {
+		// adjacent matches share a separator, so replace until nothing is left
+		for word.MatchString(text) {
+			text = word.ReplaceAllString(text, "${1}"+newName+"${2}")
+		}
+		return text
+	}
+
+	output := append([]string(nil), lines...)
+	for i := 0; i < len(output); i++ {
+		if !strings.HasPrefix(output[i], key+":") {
+			continue
+		}
+		output[i] = key + ":" + rename(output[i][len(key)+1:])
+		end := blockEnd(output, i)
+		for j := i + 1; j < end; j++ {
+			output[j] = rename(output[j])
+		}
+		break
+	}
+	return output
+}
+
+// blockEnd returns the index after the value of the top-level key at lines[start].
+// The value ends at the next unindented key. Comment and blank lines are part
+// of it, except the ones right before that key, which belong to the key.
+func blockEnd(lines []string, start int) int {
+	end := start + 1
+	for i := start + 1; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "" || strings.HasPrefix(trimmed, "#"):
+		case strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") || isListItem(line):
+			end = i + 1
+		default:
+			return end
+		}
+	}
+	return end
+}
+
+func isListItem(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, "- ") || trimmed == "-"
+}
@@ -0,0 +1,201 @@
+package meerkat
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const testConfig = `username: "watcher"
+targetoptions:
+  bob:
+    interval: 60
+    priority: 10
+  bobby: {priority: 1}
+telegramroutes:
+  - targets: ["bob", "alice"]
+    chats: [1]
+  - targets:
+      - bob
+    chats: [2]
+# bob in a comment of another option
+interval: 15
+targetusers:
+  - "bob"
+  - "alice"
+`
+
+func testMeerkat(t *testing.T, config string) *Meerkat {
+	dir, err := ioutil.TempDir("", "meerkat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	m := &Meerkat{
+		configFile:  filepath.Join(dir, "meerkat.yaml"),
+		logger:      log.New(ioutil.Discard, "", 0),
+		targetUsers: make(map[int64]User),
+		health:      make(map[string]*targetHealth),
+		schedule:    make(map[string]time.Time),
+		seen:        make(map[string]bool),
+	}
+	if err := ioutil.WriteFile(m.configFile, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := yaml.Unmarshal([]byte(config), m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestRenameTarget(t *testing.T) {
+	m := testMeerkat(t, testConfig)
+	m.targetUsers[1] = User{Username: "bob"}
+
+	if err := m.renameTarget("bob", "robert"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := m.TargetOptions["bob"]; ok || m.TargetOptions["robert"].Priority != 10 {
+		t.Errorf("target options are not moved, %v", m.TargetOptions)
+	}
+	if m.TelegramRoutes[0].Targets[0] != "robert" || m.TelegramRoutes[1].Targets[0] != "robert" {
+		t.Errorf("telegram routes are not renamed, %v", m.TelegramRoutes)
+	}
+
+	data, err := ioutil.ReadFile(m.configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := &Meerkat{}
+	if err := yaml.Unmarshal(data, saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.TargetOptions["robert"].Interval != 60 || saved.TargetOptions["bobby"].Priority != 1 {
+		t.Errorf("saved target options are %v", saved.TargetOptions)
+	}
+	if _, ok := saved.TargetOptions["bob"]; ok {
+		t.Errorf("saved target options still have bob")
+	}
+	if strings.Join(saved.TelegramRoutes[0].Targets, ",") != "robert,alice" || saved.TelegramRoutes[1].Targets[0] != "robert" {
+		t.Errorf("saved telegram routes are %v", saved.TelegramRoutes)
+	}
+	if strings.Join(saved.TargetUsers, ",") != "robert,alice" {
+		t.Errorf("saved targets are %v", saved.TargetUsers)
+	}
+	if !strings.Contains(string(data), "# bob in a comment of another option") {
+		t.Errorf("other options are changed:\n%s", data)
+	}
+}
+
+func TestRenameInBlock(t *testing.T) {
+	lines := []string{"targetoptions: {bob: {priority: 1}, bob.x: {priority: 2}}", "other: bob"}
+	got := strings.Join(renameInBlock(lines, "targetoptions", "bob", "rob"), "\n")
+	want := "targetoptions: {rob: {priority: 1}, bob.x: {priority: 2}}\nother: bob"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSaveConfigConcurrent(t *testing.T) {
+	m := testMeerkat(t, testConfig)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := m.addTarget(fmt.Sprintf("user%d", i)); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	data, err := ioutil.ReadFile(m.configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := &Meerkat{}
+	if err := yaml.Unmarshal(data, saved); err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.TargetUsers) != 22 {
+		t.Errorf("saved %d targets, want 22", len(saved.TargetUsers))
+	}
+
+	files, err := ioutil.ReadDir(filepath.Dir(m.configFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("%d files are left next to the configuration", len(files))
+	}
+}
+
+func TestReplaceTargets(t *testing.T) {
+	tests := []struct {
+		config string
+		want   string
+	}{
+		{
+			"interval: 15\ntargetusers:\n  - \"a\"\n  - \"b\"\n",
+			"interval: 15\ntargetusers: \n  - \"a\"\n  - \"c\"\n",
+		},
+		{
+			"targetusers:\n  - \"a\"\n# vip\n  - \"b\"\n\n  - \"d\"\ninterval: 15\n",
+			"targetusers: \n  - \"a\"\n  - \"c\"\ninterval: 15\n",
+		},
+		{
+			"targetusers:\n- \"a\"\n- \"b\"\n\n# sleep time\nsleeptime: 30\n",
+			"targetusers: \n  - \"a\"\n  - \"c\"\n\n# sleep time\nsleeptime: 30\n",
+		},
+		{
+			"targetusers: [\"a\", \"b\"]\ninterval: 15\n",
+			"targetusers: \n  - \"a\"\n  - \"c\"\ninterval: 15\n",
+		},
+		{
+			"interval: 15\n",
+			"interval: 15\n\ntargetusers: \n  - \"a\"\n  - \"c\"",
+		},
+	}
+
+	for _, test := range tests {
+		m := &Meerkat{TargetUsers: []string{"a", "c"}}
+		got := strings.Join(m.replaceTargets(strings.Split(test.config, "\n")), "\n")
+		if got != test.want {
+			t.Errorf("targets of %q are written as %q, want %q", test.config, got, test.want)
+		}
+	}
+}
+
+func TestRemoveTargetCommentedList(t *testing.T) {
+	m := testMeerkat(t, "targetusers:\n  - \"a\"\n  # vip\n  - \"b\"\ninterval: 15\n")
+	if err := m.addTarget("c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.removeTarget("b"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(m.configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := &Meerkat{}
+	if err := yaml.Unmarshal(data, saved); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(saved.TargetUsers, ",") != "a,c" || saved.Interval != 15 {
+		t.Errorf("saved targets are %v and interval %d:\n%s", saved.TargetUsers, saved.Interval, data)
+	}
+}
@@ -0,0 +1,205 @@
+package meerkat
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// encryptedHeader is the first line of an encrypted configuration file.
+const encryptedHeader = "# meerkat encrypted config\n"
+
+const (
+	saltSize          = 16
+	keyIterations     = 100000
+	passphraseEnv     = "MEERKAT_KEY"
+	passphraseFileEnv = "MEERKAT_KEYFILE"
+)
+
+func isEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(encryptedHeader))
+}
+
+// deriveKey stretches passphrase into an AES-256 key,
+// crypto/pbkdf2 is in the standard library since Go 1.24.
+func deriveKey(passphrase string, salt []byte) ([]byte, error) {
+	return pbkdf2.Key(sha256.New, passphrase, salt, keyIterations, 32)
+}
+
+// encryptConfig encrypts the whole configuration file with AES-GCM.
+// Output is the header followed by base64 of salt, nonce and ciphertext.
+func encryptConfig(plaintext []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	key, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	sealed := append(salt, nonce...)
+	sealed = gcm.Seal(sealed, nonce, plaintext, nil)
+	return []byte(encryptedHeader + base64.StdEncoding.EncodeToString(sealed) + "\n"), nil
+}
+
+func decryptConfig(data []byte, passphrase string) ([]byte, error) {
+	encoded := strings.TrimSpace(string(data[len(encryptedHeader):]))
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < saltSize {
+		return nil, errors.New("encrypted config is too short")
+	}
+	key, err := deriveKey(passphrase, sealed[:saltSize])
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	sealed = sealed[saltSize:]
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("encrypted config is too short")
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.New("wrong passphrase or corrupted config")
+	}
+	return plaintext, nil
+}
+
+// readPassphrase returns the passphrase of the configuration from
+// MEERKAT_KEY, from the file in MEERKAT_KEYFILE, or asks it on the terminal.
+// With confirm, the passphrase is asked twice.
+func readPassphrase(confirm bool) (string, error) {
+	if passphrase := os.Getenv(passphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+	if keyFile := os.Getenv(passphraseFileEnv); keyFile != "" {
+		bytes, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(bytes)), nil
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	passphrase, err := promptPassphrase(reader, "Config passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase is empty, set %s or %s", passphraseEnv, passphraseFileEnv)
+	}
+	if confirm {
+		repeated, err := promptPassphrase(reader, "Repeat passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if repeated != passphrase {
+			return "", errors.New("passphrases do not match")
+		}
+	}
+	return passphrase, nil
+}
+
+// promptPassphrase reads a line from reader, echo of the terminal
+// is turned off while it is typed.
+func promptPassphrase(reader *bufio.Reader, prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	if echoOff() {
+		defer func() {
+			echoOn()
+			fmt.Fprintln(os.Stderr)
+		}()
+	}
+
+	line, err := reader.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// echoOff turns off echo when stdin is a terminal, it reports
+// whether echo is turned off.
+func echoOff() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	return stty("-echo") == nil
+}
+
+func echoOn() {
+	stty("echo")
+}
+
+func stty(args ...string) error {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
+
+// cryptCommand implements `meerkat encrypt` and `meerkat decrypt`,
+// the configuration file is replaced in place.
+func cryptCommand(command, configFile string) error {
+	data, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return err
+	}
+
+	if command == "encrypt" && isEncrypted(data) {
+		return fmt.Errorf("%s is already encrypted", configFile)
+	}
+	if command == "decrypt" && !isEncrypted(data) {
+		return fmt.Errorf("%s is not encrypted", configFile)
+	}
+
+	passphrase, err := readPassphrase(command == "encrypt")
+	if err != nil {
+		return err
+	}
+
+	if command == "encrypt" {
+		data, err = encryptConfig(data, passphrase)
+	} else {
+		data, err = decryptConfig(data, passphrase)
+	}
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(configFile, data, 0600)
+}
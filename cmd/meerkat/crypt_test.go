@@ -0,0 +1,82 @@
+package meerkat
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// withStdin runs fn with input on stdin, prompts are discarded.
+func withStdin(t *testing.T, input string, fn func()) {
+	file, err := ioutil.TempFile("", "meerkat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	if _, err := file.WriteString(input); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+
+	stdin, stderr := os.Stdin, os.Stderr
+	os.Stdin, os.Stderr = file, devNull
+	defer func() { os.Stdin, os.Stderr = stdin, stderr }()
+	fn()
+}
+
+func TestReadPassphraseConfirm(t *testing.T) {
+	t.Setenv(passphraseEnv, "")
+	t.Setenv(passphraseFileEnv, "")
+
+	tests := []struct {
+		input   string
+		confirm bool
+		want    string
+		failed  bool
+	}{
+		{"secret\n", false, "secret", false},
+		{"secret\nsecret\n", true, "secret", false},
+		{"secret\nsecrte\n", true, "", true},
+		{"secret\n", true, "", true},
+		{"\n", false, "", true},
+	}
+
+	for _, test := range tests {
+		withStdin(t, test.input, func() {
+			got, err := readPassphrase(test.confirm)
+			if (err != nil) != test.failed || got != test.want {
+				t.Errorf("input %q, passphrase is %q, %v", test.input, got, err)
+			}
+		})
+	}
+}
+
+func TestEncryptConfig(t *testing.T) {
+	data := []byte("username: watcher\n")
+	sealed, err := encryptConfig(data, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isEncrypted(sealed) {
+		t.Fatal("encrypted config has no header")
+	}
+	if _, err := decryptConfig(sealed, "wrong"); err == nil {
+		t.Error("decrypted with a wrong passphrase")
+	}
+	plain, err := decryptConfig(sealed, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plain) != string(data) {
+		t.Errorf("decrypted %q, want %q", plain, data)
+	}
+}
@@ -0,0 +1,151 @@
+package meerkat
+
+import (
+	"time"
+)
+
+// engagementSamples is the number of samples kept for each post.
+const engagementSamples = 100
+
+// EngagementSample is the number of likes and comments of a post at a time.
+type EngagementSample struct {
+	Time     time.Time
+	Likes    int
+	Comments int
+}
+
+// PostEngagement is the engagement of one of the latest posts of a target.
+type PostEngagement struct {
+	Code     string
+	TakenAt  int64
+	Likes    int
+	Comments int
+	// Samples are taken when the counts change, oldest first.
+	Samples []EngagementSample
+	// LikedBy are other targets which liked the post, by user ID.
+	LikedBy map[int64]string
+}
+
+// crossed returns the thresholds which are passed from old to new.
+func crossed(thresholds []int, old, new int) []int {
+	passed := []int{}
+	for _, threshold := range thresholds {
+		if old < threshold && new >= threshold {
+			passed = append(passed, threshold)
+		}
+	}
+	return passed
+}
+
+// checkEngagement tracks likes and comments of the watchengagement latest
+// posts of a target. It reports when a post passes likethresholds or
+// commentthresholds, and when another target likes a post.
+// Posts seen for the first time are only stored.
+func (m *Meerkat) checkEngagement(userID int64, user *User) error {
+	if m.WatchEngagement <= 0 {
+		return nil
+	}
+
+	feed, err := m.instagram.LatestUserFeed(userID)
+	if err != nil {
+		return err
+	}
+	user.RecentPosts = recentPosts(feed.Items)
+
+	items := feed.Items
+	if len(items) > m.WatchEngagement {
+		items = items[:m.WatchEngagement]
+	}
+
+	now := time.Now()
+	known := user.Engagement
+	user.Engagement = make(map[string]*PostEngagement)
+	for _, item := range items {
+		post, ok := known[item.ID]
+		if !ok {
+			post = &PostEngagement{Code: item.Code, TakenAt: item.TakenAt}
+		}
+		user.Engagement[item.ID] = post
+
+		media := &Media{
+			ID:      item.ID,
+			Code:    item.Code,
+			URL:     postURL(item.Code),
+			Type:    mediaTypeName(item.MediaType),
+			TakenAt: time.Unix(item.TakenAt, 0),
+		}
+
+		if ok {
+			for _, threshold := range crossed(m.LikeThresholds, post.Likes, item.LikeCount) {
+				m.sendEngagement(userID, user.Username, media, "likes", threshold, item.LikeCount)
+			}
+			for _, threshold := range crossed(m.CommentThresholds, post.Comments, item.CommentCount) {
+				m.sendEngagement(userID, user.Username, media, "comments", threshold, item.CommentCount)
+			}
+		}
+
+		if !ok || post.Likes != item.LikeCount || post.Comments != item.CommentCount {
+			post.Likes, post.Comments = item.LikeCount, item.CommentCount
+			post.Samples = append(post.Samples, EngagementSample{Time: now, Likes: post.Likes, Comments: post.Comments})
+			if len(post.Samples) > engagementSamples {
+				post.Samples = post.Samples[len(post.Samples)-engagementSamples:]
+			}
+		}
+
+		if err := m.checkLikers(userID, user.Username, post, media, ok); err != nil {
+			m.logger.Printf("Error, likers of %s post %s, %s", user.Username, item.Code, err.Error())
+		}
+	}
+	return nil
+}
+
+// checkLikers records other targets which liked the post,
+// and reports them unless the post is seen for the first time.
+func (m *Meerkat) checkLikers(userID int64, username string, post *PostEngagement, media *Media, report bool) error {
+	likers, err := m.instagram.MediaLikers(media.ID)
+	if err != nil {
+		return err
+	}
+
+	if post.LikedBy == nil {
+		post.LikedBy = make(map[int64]string)
+	}
+	for _, liker := range likers.Users {
+		if liker.ID == userID {
+			continue
+		}
+		if _, ok := post.LikedBy[liker.ID]; ok {
+			continue
+		}
+		if _, ok := m.targetUser(liker.ID); !ok {
+			continue
+		}
+		post.LikedBy[liker.ID] = liker.Username
+
+		if report {
+			m.send(&Event{
+				Kind:          EventLiked,
+				UserID:        userID,
+				Username:      username,
+				OtherUserID:   liker.ID,
+				OtherUsername: liker.Username,
+				Media:         media,
+				Timestamp:     time.Now(),
+			})
+		}
+	}
+	return nil
+}
+
+func (m *Meerkat) sendEngagement(userID int64, username string, media *Media, field string, threshold, count int) {
+	m.send(&Event{
+		Kind:      EventEngagement,
+		UserID:    userID,
+		Username:  username,
+		Field:     field,
+		OldValue:  threshold,
+		NewValue:  count,
+		Media:     media,
+		Timestamp: time.Now(),
+	})
+}
@@ -0,0 +1,145 @@
+package meerkat
+
+import (
+	"fmt"
+	"time"
+)
+
+// EventKind is the type of an event produced by the watcher.
+type EventKind string
+
+const (
+	// EventActivity is a story of the following activity feed about a target.
+	EventActivity EventKind = "activity"
+	// EventProfile is a change in one field of a target profile.
+	EventProfile EventKind = "profile"
+	// EventFollow is a new user in following or followers list of a target.
+	EventFollow EventKind = "follow"
+	// EventUnfollow is a user removed from following or followers list of a target.
+	EventUnfollow EventKind = "unfollow"
+	// EventPost is a new post shared by a target.
+	EventPost EventKind = "post"
+	// EventStory is a new story item of a target.
+	EventStory EventKind = "story"
+	// EventRename is a change of a target username.
+	EventRename EventKind = "rename"
+	// EventUnreachable means requests of a target have failed several times.
+	EventUnreachable EventKind = "unreachable"
+	// EventReachable means an unreachable target can be checked again.
+	EventReachable EventKind = "reachable"
+	// EventBudget means requestsperhour is not enough for the targets.
+	EventBudget EventKind = "budget"
+	// EventComment is a new comment on a recent post of a target.
+	EventComment EventKind = "comment"
+	// EventCommentRemoved is a comment which disappeared from a recent post of a target.
+	EventCommentRemoved EventKind = "comment_removed"
+	// EventEngagement means likes or comments of a post of a target passed a threshold.
+	EventEngagement EventKind = "engagement"
+	// EventLiked means another target liked a post of a target.
+	EventLiked EventKind = "liked"
+	// EventAccountDown means a watcher account is logged out or rate limited.
+	EventAccountDown EventKind = "account_down"
+	// EventAccountUp means a watcher account is used again.
+	EventAccountUp EventKind = "account_up"
+)
+
+// Event is a single change detected by meerkat.
+// Outputs render it for humans, or deliver it as data to machines.
+type Event struct {
+	Kind      EventKind   `json:"kind"`
+	UserID    int64       `json:"user_id"`
+	Username  string      `json:"username"`
+	Field     string      `json:"field,omitempty"`
+	OldValue  interface{} `json:"old_value,omitempty"`
+	NewValue  interface{} `json:"new_value,omitempty"`
+	Timestamp time.Time   `json:"timestamp"`
+
+	// OtherUserID and OtherUsername are the other side of follow events,
+	// OtherUsername is the watcher account of account events.
+	OtherUserID   int64  `json:"other_user_id,omitempty"`
+	OtherUsername string `json:"other_username,omitempty"`
+
+	// Media is the post or story of media events.
+	Media *Media `json:"media,omitempty"`
+	// CommentID is the comment of comment events.
+	CommentID int64 `json:"comment_id,omitempty"`
+
+	// StoryID and Story are the source story of activity events,
+	// Action is the story parsed.
+	StoryID string  `json:"story_id,omitempty"`
+	Story   string  `json:"story,omitempty"`
+	Action  *Action `json:"action,omitempty"`
+}
+
+// Media is a post or a story of Instagram.
+type Media struct {
+	ID       string    `json:"id"`
+	Code     string    `json:"code,omitempty"`
+	URL      string    `json:"url,omitempty"`
+	Type     string    `json:"type"`
+	Caption  string    `json:"caption,omitempty"`
+	Location string    `json:"location,omitempty"`
+	TakenAt  time.Time `json:"taken_at"`
+
+	// Files are the archived files of the media.
+	Files []string `json:"files,omitempty"`
+}
+
+// String is the default human readable message of the event.
+func (e *Event) String() string {
+	header := fmt.Sprintf("[%s] [%s]", e.Username, e.Timestamp.Format("2006-01-02 15:04:05 MST"))
+	if e.Username == "" {
+		header = fmt.Sprintf("[%s]", e.Timestamp.Format("2006-01-02 15:04:05 MST"))
+	}
+
+	switch e.Kind {
+	case EventActivity:
+		return fmt.Sprintf("%s %s", header, e.Story)
+	case EventProfile:
+		if e.Field == "biography" {
+			return fmt.Sprintf("%s User %s biography changed to %v", header, e.Username, e.NewValue)
+		}
+		return fmt.Sprintf("%s User %s %s changed from %v to %v", header, e.Username, e.Field, e.OldValue, e.NewValue)
+	case EventFollow, EventUnfollow:
+		action := "started following"
+		if e.Kind == EventUnfollow {
+			action = "unfollowed"
+		}
+		if e.Field == "followers" {
+			return fmt.Sprintf("%s %s %s %s", header, e.OtherUsername, action, e.Username)
+		}
+		return fmt.Sprintf("%s %s %s %s", header, e.Username, action, e.OtherUsername)
+	case EventPost:
+		message := fmt.Sprintf("%s User %s shared a new %s %s", header, e.Username, e.Media.Type, e.Media.URL)
+		if e.Media.Location != "" {
+			message += "\nLocation : " + e.Media.Location
+		}
+		if e.Media.Caption != "" {
+			message += "\n" + e.Media.Caption
+		}
+		return message
+	case EventRename:
+		return fmt.Sprintf("%s User %s renamed to %s", header, e.OldValue, e.NewValue)
+	case EventUnreachable:
+		return fmt.Sprintf("%s User %s is unreachable, %v", header, e.Username, e.NewValue)
+	case EventReachable:
+		return fmt.Sprintf("%s User %s is reachable again", header, e.Username)
+	case EventBudget:
+		return fmt.Sprintf("%s Targets need about %v requests per hour, but requestsperhour is %v", header, e.NewValue, e.OldValue)
+	case EventComment:
+		return fmt.Sprintf("%s %s commented on %s post %s : %v", header, e.OtherUsername, e.Username, e.Media.URL, e.NewValue)
+	case EventCommentRemoved:
+		return fmt.Sprintf("%s Comment of %s on %s post %s disappeared : %v", header, e.OtherUsername, e.Username, e.Media.URL, e.OldValue)
+	case EventEngagement:
+		return fmt.Sprintf("%s Post %s of %s passed %v %s, it has %v now", header, e.Media.URL, e.Username, e.OldValue, e.Field, e.NewValue)
+	case EventLiked:
+		return fmt.Sprintf("%s %s liked %s post %s", header, e.OtherUsername, e.Username, e.Media.URL)
+	case EventAccountDown:
+		return fmt.Sprintf("%s Account %s is not used for a while, %v", header, e.OtherUsername, e.NewValue)
+	case EventAccountUp:
+		return fmt.Sprintf("%s Account %s is used again", header, e.OtherUsername)
+	case EventStory:
+		return fmt.Sprintf("%s User %s added a %s story %s", header, e.Username, e.Media.Type, e.Media.URL)
+	}
+	return fmt.Sprintf("%s %s", header, e.Kind)
+}
@@ -0,0 +1,128 @@
+package meerkat
+
+import (
+	"time"
+
+	"github.com/ahmdrz/goinsta/response"
+)
+
+// followListPages is the number of list pages fetched in one check of a target,
+// so long lists are fetched over several checks instead of a burst of requests.
+const followListPages = 5
+
+// ListFetch is a following or followers list which is being fetched.
+type ListFetch struct {
+	Users map[int64]string
+	MaxID string
+	Done  bool
+}
+
+// fetchPages fetches the next pages of list, each page is spent from the request budget.
+// It returns false when the budget or followListPages stops it before the end of the list.
+func (m *Meerkat) fetchPages(list *ListFetch, pages *int, fetch func(maxID string) (response.UsersResponse, error)) (bool, error) {
+	if list.Users == nil {
+		list.Users = make(map[int64]string)
+	}
+	for !list.Done {
+		if *pages <= 0 || !m.spend(1) {
+			return false, nil
+		}
+		*pages--
+
+		resp, err := fetch(list.MaxID)
+		if err != nil {
+			return false, err
+		}
+		for id, username := range usersMap(resp.Users) {
+			list.Users[id] = username
+		}
+		list.MaxID = resp.NextMaxID
+		list.Done = !resp.BigList
+	}
+	return true, nil
+}
+
+// checkFollowLists fetches the full follower and following lists of a target
+// every followlistinterval minutes, and reports who has been followed or unfollowed.
+// Lists are fetched page by page over several checks within the request budget.
+// Full lists are expensive, so it is disabled when followlistinterval is zero.
+func (m *Meerkat) checkFollowLists(userID int64, user *User) error {
+	if m.FollowListInterval <= 0 {
+		return nil
+	}
+	if user.FollowingFetch == nil || user.FollowerFetch == nil {
+		if time.Since(user.ListsCheckedAt) < time.Duration(m.FollowListInterval)*time.Minute {
+			return nil
+		}
+		m.logger.Printf("Getting %s following and followers lists", user.Username)
+		user.FollowingFetch = &ListFetch{Users: make(map[int64]string)}
+		user.FollowerFetch = &ListFetch{Users: make(map[int64]string)}
+	}
+
+	pages := followListPages
+	done, err := m.fetchPages(user.FollowingFetch, &pages, func(maxID string) (response.UsersResponse, error) {
+		return m.instagram.UserFollowing(userID, maxID)
+	})
+	if err != nil || !done {
+		return err
+	}
+	done, err = m.fetchPages(user.FollowerFetch, &pages, func(maxID string) (response.UsersResponse, error) {
+		return m.instagram.UserFollowers(userID, maxID)
+	})
+	if err != nil || !done {
+		return err
+	}
+
+	followingList := user.FollowingFetch.Users
+	followerList := user.FollowerFetch.Users
+
+	// the first lists are only stored
+	if !user.ListsCheckedAt.IsZero() {
+		now := time.Now()
+		m.diffList(userID, user.Username, "following", user.FollowingList, followingList, now)
+		m.diffList(userID, user.Username, "followers", user.FollowerList, followerList, now)
+	}
+
+	user.FollowingList = followingList
+	user.FollowerList = followerList
+	user.FollowingFetch, user.FollowerFetch = nil, nil
+	user.ListsCheckedAt = time.Now()
+	return nil
+}
+
+func (m *Meerkat) diffList(userID int64, username, field string, oldList, newList map[int64]string, now time.Time) {
+	for id, other := range newList {
+		if _, ok := oldList[id]; !ok {
+			m.send(&Event{
+				Kind:          EventFollow,
+				UserID:        userID,
+				Username:      username,
+				Field:         field,
+				OtherUserID:   id,
+				OtherUsername: other,
+				Timestamp:     now,
+			})
+		}
+	}
+	for id, other := range oldList {
+		if _, ok := newList[id]; !ok {
+			m.send(&Event{
+				Kind:          EventUnfollow,
+				UserID:        userID,
+				Username:      username,
+				Field:         field,
+				OtherUserID:   id,
+				OtherUsername: other,
+				Timestamp:     now,
+			})
+		}
+	}
+}
+
+func usersMap(users []response.User) map[int64]string {
+	result := make(map[int64]string, len(users))
+	for _, user := range users {
+		result[user.ID] = user.Username
+	}
+	return result
+}
@@ -0,0 +1,201 @@
+package meerkat
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/ahmdrz/goinsta"
+)
+
+type errorClass int
+
+const (
+	errorNone errorClass = iota
+	// errorTransient is a network error, the request may succeed later.
+	errorTransient
+	// errorNotFound is a deleted or renamed user.
+	errorNotFound
+	// errorLoggedOut means the account has to log in again.
+	errorLoggedOut
+	// errorRateLimited means the account has sent too many requests.
+	errorRateLimited
+	errorOther
+)
+
+func classifyError(err error) errorClass {
+	switch err {
+	case nil:
+		return errorNone
+	case goinsta.ErrLoggedOut:
+		return errorLoggedOut
+	case goinsta.ErrNotFound:
+		return errorNotFound
+	}
+	if isRateLimited(err) {
+		return errorRateLimited
+	}
+
+	switch err.(type) {
+	case net.Error:
+		return errorTransient
+	case *json.SyntaxError:
+		// truncated response
+		return errorTransient
+	}
+	return errorOther
+}
+
+// targetError reports if err is caused by the target,
+// and not by the network or the accounts.
+func targetError(err error) bool {
+	switch classifyError(err) {
+	case errorTransient, errorLoggedOut, errorRateLimited:
+		return false
+	}
+	return true
+}
+
+// rateLimitMessages are in the responses of a rate limited account,
+// goinsta returns them as an invalid status code error.
+var rateLimitMessages = []string{
+	"Please wait a few minutes",
+	"rate_limit",
+}
+
+func isRateLimited(err error) bool {
+	if !strings.HasPrefix(err.Error(), "Invalid status code") {
+		return false
+	}
+	for _, message := range rateLimitMessages {
+		if strings.Contains(err.Error(), message) {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	// unreachableAfter is the number of consecutive failures
+	// before a target is reported as unreachable.
+	unreachableAfter = 3
+	maxBackoff       = time.Hour
+	// failoverAccounts is the number of other accounts which check a target
+	// in the same job when its account can not be used.
+	failoverAccounts = 1
+)
+
+// targetHealth is the state of requests of one target.
+// Failing targets are skipped until NextCheck, so they do not affect the others.
+type targetHealth struct {
+	Failures    int
+	LastError   string
+	NextCheck   time.Time
+	Unreachable bool
+}
+
+// backoff returns the delay before the next check of a target after failures,
+// it grows exponentially from interval up to maxBackoff with a random jitter.
+func (m *Meerkat) backoff(failures int) time.Duration {
+	delay := time.Duration(m.Interval) * time.Second
+	if delay < 10*time.Second {
+		delay = 10 * time.Second
+	}
+	for i := 1; i < failures && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay)))
+}
+
+// checkTarget checks username with its account and keeps its health.
+// When the account is logged out it logs in again, and when the account
+// can not be used the target is checked by another account. A failure of
+// that account too is kept in the health of the target, so one target
+// does not take the whole pool down in a single job.
+func (m *Meerkat) checkTarget(username string) {
+	var err error
+	for attempt := 0; attempt <= failoverAccounts; attempt++ {
+		acc := m.pickAccount(username)
+		if acc == nil {
+			m.logger.Println("Error, there is no account to check", username)
+			if err == nil {
+				return
+			}
+			break
+		}
+
+		var ok bool
+		ok, err = m.tryAccount(acc, func() error {
+			current, err := m.checkUser(username)
+			// the health of a renamed target is kept under its new username
+			username = current
+			return err
+		})
+		if ok {
+			break
+		}
+	}
+	m.recordHealth(username, err)
+}
+
+func (m *Meerkat) recordHealth(username string, err error) {
+	userID, _, _ := m.targetByUsername(username)
+
+	m.mu.Lock()
+	if !m.isTarget(username) {
+		// removed or renamed while it was checked
+		m.mu.Unlock()
+		return
+	}
+	h, ok := m.health[username]
+	if !ok {
+		h = &targetHealth{}
+		m.health[username] = h
+	}
+
+	if err == nil {
+		recovered := h.Unreachable
+		*h = targetHealth{}
+		m.mu.Unlock()
+
+		if recovered {
+			m.send(&Event{
+				Kind:      EventReachable,
+				UserID:    userID,
+				Username:  username,
+				Timestamp: time.Now(),
+			})
+		}
+		return
+	}
+
+	h.Failures++
+	h.LastError = err.Error()
+	delay := m.backoff(h.Failures)
+	h.NextCheck = time.Now().Add(delay)
+	notify := false
+	if targetError(err) && h.Failures >= unreachableAfter && !h.Unreachable {
+		h.Unreachable = true
+		notify = true
+	}
+	failures := h.Failures
+	m.mu.Unlock()
+
+	m.logger.Printf("Error, %s failed %d times, next check in %s, %s", username, failures, delay.Round(time.Second), err.Error())
+
+	if notify {
+		m.send(&Event{
+			Kind:      EventUnreachable,
+			UserID:    userID,
+			Username:  username,
+			Field:     "error",
+			NewValue:  err.Error(),
+			Timestamp: time.Now(),
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package meerkat
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ahmdrz/goinsta"
+)
+
+func TestRecordHealthAccountErrors(t *testing.T) {
+	tests := []struct {
+		err         error
+		unreachable bool
+	}{
+		{errors.New(`Invalid status code {"message": "Please wait a few minutes before you try again.", "status": "fail"}`), false},
+		{goinsta.ErrLoggedOut, false},
+		{goinsta.ErrNotFound, true},
+		{errors.New("private account"), true},
+	}
+
+	for _, test := range tests {
+		m := testMeerkat(t, testConfig)
+		for i := 0; i < unreachableAfter; i++ {
+			m.recordHealth("bob", test.err)
+		}
+
+		h := m.health["bob"]
+		if h == nil || h.Failures != unreachableAfter {
+			t.Fatalf("%v, health is %v", test.err, h)
+		}
+		if h.Unreachable != test.unreachable {
+			t.Errorf("%v, unreachable is %v, want %v", test.err, h.Unreachable, test.unreachable)
+		}
+		if h.NextCheck.IsZero() {
+			t.Errorf("%v, next check is not delayed", test.err)
+		}
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want errorClass
+	}{
+		{nil, errorNone},
+		{goinsta.ErrLoggedOut, errorLoggedOut},
+		{goinsta.ErrNotFound, errorNotFound},
+		{errors.New(`Invalid status code {"message": "Please wait a few minutes before you try again.", "status": "fail"}`), errorRateLimited},
+		{errors.New(`Invalid status code {"message": "rate_limit_error", "status": "fail"}`), errorRateLimited},
+		{errors.New(`Invalid status code {"message": "challenge_required", "status": "fail"}`), errorOther},
+		{errors.New("Please wait a few minutes"), errorOther},
+	}
+
+	for _, test := range tests {
+		if got := classifyError(test.err); got != test.want {
+			t.Errorf("%v is class %d, want %d", test.err, got, test.want)
+		}
+	}
+}
+
+func TestRecordHealthAfterRename(t *testing.T) {
+	m := testMeerkat(t, testConfig)
+	m.targetUsers[1] = User{Username: "bob"}
+	for i := 0; i < unreachableAfter; i++ {
+		m.recordHealth("bob", goinsta.ErrNotFound)
+	}
+	m.events = nil
+
+	if err := m.renameTarget("bob", "robert"); err != nil {
+		t.Fatal(err)
+	}
+	m.recordHealth("robert", nil)
+
+	if h := m.health["robert"]; h == nil || h.Failures != 0 || h.Unreachable {
+		t.Errorf("health of the renamed target is %+v", h)
+	}
+	if len(m.events) != 1 || m.events[0].Kind != EventReachable || m.events[0].Username != "robert" {
+		t.Errorf("events are %v, want reachable robert", m.events)
+	}
+}
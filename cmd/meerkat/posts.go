@@ -0,0 +1,122 @@
+package meerkat
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ahmdrz/goinsta/response"
+)
+
+// Instagram media types
+const (
+	mediaImage    = 1
+	mediaVideo    = 2
+	mediaCarousel = 8
+)
+
+func mediaTypeName(mediaType int) string {
+	switch mediaType {
+	case mediaImage:
+		return "image"
+	case mediaVideo:
+		return "video"
+	case mediaCarousel:
+		return "carousel"
+	}
+	return "unknown"
+}
+
+func postURL(code string) string {
+	return "https://www.instagram.com/p/" + code + "/"
+}
+
+// checkPosts reports new posts of a target when watchposts is enabled.
+// The feed is requested only when the number of posts has changed,
+// or when the posts of the target have never been checked.
+func (m *Meerkat) checkPosts(userID int64, user *User, postsChanged bool) error {
+	if !m.WatchPosts {
+		return nil
+	}
+	if !postsChanged && !user.PostsCheckedAt.IsZero() {
+		return nil
+	}
+
+	feed, err := m.instagram.LatestUserFeed(userID)
+	if err != nil {
+		return err
+	}
+	user.RecentPosts = recentPosts(feed.Items)
+
+	lastPostAt := user.LastPostAt
+	for _, item := range feed.Items {
+		if item.TakenAt > lastPostAt {
+			lastPostAt = item.TakenAt
+		}
+		// the first feed is only stored
+		if user.PostsCheckedAt.IsZero() || item.TakenAt <= user.LastPostAt {
+			continue
+		}
+
+		m.send(&Event{
+			Kind:      EventPost,
+			UserID:    userID,
+			Username:  user.Username,
+			Media:     m.postMedia(user.Username, item),
+			Timestamp: time.Unix(item.TakenAt, 0),
+		})
+	}
+
+	user.LastPostAt = lastPostAt
+	user.PostsCheckedAt = time.Now()
+	return nil
+}
+
+// postMedia converts a feed item to Media,
+// and archives its images and videos when archivedir is set.
+func (m *Meerkat) postMedia(username string, item response.Item) *Media {
+	media := &Media{
+		ID:      item.ID,
+		Code:    item.Code,
+		URL:     postURL(item.Code),
+		Type:    mediaTypeName(item.MediaType),
+		Caption: item.Caption.Text,
+		TakenAt: time.Unix(item.TakenAt, 0),
+	}
+	if item.Location != nil {
+		media.Location = item.Location.Name
+	}
+
+	if m.ArchiveDir == "" {
+		return media
+	}
+
+	urls := []string{}
+	switch item.MediaType {
+	case mediaCarousel:
+		for _, child := range item.CarouselMedia {
+			if child.MediaType == mediaVideo && len(child.VideoVersions) > 0 {
+				urls = append(urls, child.VideoVersions[0].URL)
+			} else if len(child.ImageVersions.Candidates) > 0 {
+				urls = append(urls, child.ImageVersions.Candidates[0].URL)
+			}
+		}
+	case mediaVideo:
+		if len(item.VideoVersions) > 0 {
+			urls = append(urls, item.VideoVersions[0].URL)
+		}
+	default:
+		if len(item.ImageVersions2.Candidates) > 0 {
+			urls = append(urls, item.ImageVersions2.Candidates[0].URL)
+		}
+	}
+
+	for i, url := range urls {
+		file, err := m.archive(username, fmt.Sprintf("post_%s_%d", item.Code, i+1), url)
+		if err != nil {
+			m.logger.Println("Error", err)
+			continue
+		}
+		media.Files = append(media.Files, file)
+	}
+	return media
+}
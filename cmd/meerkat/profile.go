@@ -0,0 +1,102 @@
+package meerkat
+
+import (
+	"time"
+
+	"github.com/ahmdrz/goinsta/response"
+)
+
+// checkProfile updates the snapshot of a target with info,
+// and reports the changed fields when report is true.
+func (m *Meerkat) checkProfile(info response.GetUsernameResponse, user *User, report bool) {
+	profile := info.User
+	picURL := profile.HdProfilePicURLInfo.URL
+	if picURL == "" {
+		picURL = profile.ProfilePicURL
+	}
+
+	if report {
+		now := time.Now()
+		diff := func(field string, oldValue, newValue interface{}) {
+			if oldValue == newValue {
+				return
+			}
+			m.send(&Event{
+				Kind:      EventProfile,
+				UserID:    profile.ID,
+				Username:  user.Username,
+				Field:     field,
+				OldValue:  oldValue,
+				NewValue:  newValue,
+				Timestamp: now,
+			})
+		}
+
+		diff("biography", user.Bio, profile.Biography)
+		diff("followers", user.Followers, profile.FollowerCount)
+		diff("following", user.Following, profile.FollowingCount)
+		diff("posts", user.Posts, profile.MediaCount)
+		diff("tags", user.Tags, profile.UserTagsCount)
+		diff("full name", user.FullName, profile.FullName)
+		diff("external url", user.ExternalURL, profile.ExternalURL)
+		diff("private", user.Private, profile.IsPrivate)
+		diff("verified", user.Verified, profile.IsVerified)
+		diff("business", user.Business, profile.IsBusiness)
+
+		if user.ProfilePicID != profile.ProfilePicID {
+			m.send(&Event{
+				Kind:      EventProfile,
+				UserID:    profile.ID,
+				Username:  user.Username,
+				Field:     "profile picture",
+				OldValue:  user.ProfilePicURL,
+				NewValue:  picURL,
+				Media:     m.profilePicture(user.Username, profile.ProfilePicID, picURL),
+				Timestamp: now,
+			})
+		}
+	}
+
+	// archive the first picture too, so the previous picture is kept after a change
+	if !report {
+		m.profilePicture(user.Username, profile.ProfilePicID, picURL)
+	}
+
+	user.Bio = profile.Biography
+	user.Followers = profile.FollowerCount
+	user.Following = profile.FollowingCount
+	user.Posts = profile.MediaCount
+	user.Tags = profile.UserTagsCount
+	user.FullName = profile.FullName
+	user.ExternalURL = profile.ExternalURL
+	user.ProfilePicID = profile.ProfilePicID
+	user.ProfilePicURL = picURL
+	user.Private = profile.IsPrivate
+	user.Verified = profile.IsVerified
+	user.Business = profile.IsBusiness
+}
+
+// profilePicture returns the profile picture as Media,
+// and archives it when archivedir is set.
+func (m *Meerkat) profilePicture(username, picID, picURL string) *Media {
+	media := &Media{
+		ID:   picID,
+		URL:  picURL,
+		Type: "image",
+	}
+	if m.ArchiveDir == "" || picURL == "" {
+		return media
+	}
+
+	name := "profile_" + picID
+	if picID == "" {
+		name = "profile_anonymous"
+	}
+	file, err := m.archive(username, name, picURL)
+	if err != nil {
+		m.logger.Println("Error", err)
+		return media
+	}
+	media.Files = append(media.Files, file)
+	return media
+}
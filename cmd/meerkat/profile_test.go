@@ -0,0 +1,62 @@
+package meerkat
+
+import (
+	"testing"
+
+	"github.com/ahmdrz/goinsta/response"
+)
+
+// TestCheckProfileCounts guards the posts field, which is compared
+// with MediaCount and not with FollowingCount as it was before events.
+func TestCheckProfileCounts(t *testing.T) {
+	m := testMeerkat(t, testConfig)
+	user := &User{Username: "bob", Followers: 10, Following: 20, Posts: 30, Tags: 40}
+
+	info := response.GetUsernameResponse{}
+	info.User.FollowerCount = 10
+	info.User.FollowingCount = 20
+	info.User.MediaCount = 31
+	info.User.UserTagsCount = 40
+	m.checkProfile(info, user, true)
+
+	if len(m.events) != 1 {
+		t.Fatalf("%d events, want 1", len(m.events))
+	}
+	event := m.events[0]
+	if event.Field != "posts" || event.OldValue != 30 || event.NewValue != 31 {
+		t.Errorf("event is %s from %v to %v, want posts from 30 to 31", event.Field, event.OldValue, event.NewValue)
+	}
+	if user.Posts != 31 || user.Following != 20 {
+		t.Errorf("snapshot has %d posts and %d following", user.Posts, user.Following)
+	}
+
+	m.events = nil
+	info.User.FollowingCount = 21
+	m.checkProfile(info, user, true)
+	if len(m.events) != 1 || m.events[0].Field != "following" {
+		t.Errorf("events are %v, want a following change only", m.events)
+	}
+}
+
+func TestCheckProfileFirstSnapshot(t *testing.T) {
+	m := testMeerkat(t, testConfig)
+	user := &User{Username: "bob"}
+
+	info := response.GetUsernameResponse{}
+	info.User.FullName = "Bob"
+	info.User.MediaCount = 3
+	info.User.ProfilePicID = "pic"
+	m.checkProfile(info, user, false)
+
+	if len(m.events) != 0 {
+		t.Errorf("first snapshot reported %d events", len(m.events))
+	}
+	if user.FullName != "Bob" || user.Posts != 3 || user.ProfilePicID != "pic" {
+		t.Errorf("snapshot is %+v", user)
+	}
+
+	m.checkProfile(info, user, true)
+	if len(m.events) != 0 {
+		t.Errorf("unchanged profile reported %d events", len(m.events))
+	}
+}
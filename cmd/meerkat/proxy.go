@@ -0,0 +1,265 @@
+package meerkat
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	// proxyFailures is the number of consecutive network errors
+	// before an account leaves its proxy.
+	proxyFailures     = 3
+	proxyCooldown     = 10 * time.Minute
+	proxyCheckTimeout = 10 * time.Second
+	proxyCheckURL     = "https://i.instagram.com/"
+)
+
+// proxy is a proxy of the pool and its health.
+// Its fields are guarded by m.mu.
+type proxy struct {
+	url       string
+	failures  int
+	downUntil time.Time
+	lastError string
+	checking  bool
+}
+
+// parseProxy validates an HTTP or SOCKS5 proxy url.
+func parseProxy(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("proxy %s, %s", rawURL, err.Error())
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("proxy %s, scheme should be http, https, socks5 or socks5h", proxyHost(rawURL))
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy %s has no host", proxyHost(rawURL))
+	}
+	return u, nil
+}
+
+// proxyHost hides the credentials of a proxy url in messages.
+func proxyHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "invalid proxy"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// checkProxy sends a request to Instagram through the proxy.
+func checkProxy(rawURL string) error {
+	u, err := parseProxy(rawURL)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{
+		Timeout:   proxyCheckTimeout,
+		Transport: &http.Transport{Proxy: http.ProxyURL(u)},
+	}
+	resp, err := client.Head(proxyCheckURL)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// loadProxies makes the proxy pool of proxy and proxies,
+// and validates the proxies of accounts.
+func (m *Meerkat) loadProxies() error {
+	urls := m.Proxies
+	if m.Proxy != "" {
+		urls = append([]string{m.Proxy}, urls...)
+	}
+	for _, rawURL := range urls {
+		if _, err := parseProxy(rawURL); err != nil {
+			return err
+		}
+		m.proxies = append(m.proxies, &proxy{url: rawURL})
+	}
+	for _, a := range m.Accounts {
+		if a.Proxy == "" {
+			continue
+		}
+		if _, err := parseProxy(a.Proxy); err != nil {
+			return fmt.Errorf("Account %s, %s", a.Username, err.Error())
+		}
+	}
+	return nil
+}
+
+// proxyURL is the proxy of the next requests of acc,
+// its own proxy or the one it has from the pool.
+func (m *Meerkat) proxyURL(acc *account) string {
+	if acc.Proxy != "" {
+		return acc.Proxy
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if acc.proxy == nil {
+		return ""
+	}
+	return acc.proxy.url
+}
+
+// assignProxies gives each account without its own proxy a proxy of the pool,
+// accounts are spread over the pool and dead proxies are skipped.
+func (m *Meerkat) assignProxies() {
+	if len(m.proxies) == 0 {
+		return
+	}
+	for i, acc := range m.accounts {
+		if acc.Proxy == "" {
+			m.rotateProxy(acc, i)
+		}
+	}
+	m.nextRotation = time.Now().Add(time.Duration(m.ProxyRotation) * time.Second)
+}
+
+// rotateProxy moves acc to the first proxy of the pool from start which is
+// not down, and checks it in background. When every proxy is down, acc keeps
+// its proxy or takes the one at start, so its requests are never sent without a proxy.
+func (m *Meerkat) rotateProxy(acc *account, start int) {
+	now := time.Now()
+
+	m.mu.Lock()
+	next := acc.proxy
+	if next == nil {
+		next = m.proxies[start%len(m.proxies)]
+	}
+	for i := 0; i < len(m.proxies); i++ {
+		p := m.proxies[(start+i)%len(m.proxies)]
+		if !now.Before(p.downUntil) {
+			next = p
+			break
+		}
+	}
+	changed := acc.proxy != next
+	acc.proxy = next
+	m.mu.Unlock()
+
+	m.verifyProxy(next)
+
+	if acc.instagram != nil {
+		acc.instagram.Proxy = next.url
+	}
+	if changed {
+		m.logger.Printf("Account %s uses proxy %s", acc.Username, proxyHost(next.url))
+	}
+}
+
+// verifyProxy checks p in background, so a slow proxy does not block
+// the watcher. Only one check of a proxy runs at a time. A failing proxy
+// is taken out of the pool, and its accounts leave it on the next slot.
+func (m *Meerkat) verifyProxy(p *proxy) {
+	m.mu.Lock()
+	if p.checking {
+		m.mu.Unlock()
+		return
+	}
+	p.checking = true
+	m.mu.Unlock()
+
+	go func() {
+		err := checkProxy(p.url)
+
+		m.mu.Lock()
+		p.checking = false
+		m.mu.Unlock()
+
+		if err != nil {
+			m.proxyDown(p, err)
+		}
+	}()
+}
+
+// rotateProxies moves accounts of the pool off proxies which are down,
+// and every account to the next proxy every proxyrotation seconds.
+func (m *Meerkat) rotateProxies(now time.Time) {
+	if len(m.proxies) == 0 {
+		return
+	}
+	rotate := m.ProxyRotation > 0 && len(m.proxies) > 1 && !now.Before(m.nextRotation)
+	if rotate {
+		m.nextRotation = now.Add(time.Duration(m.ProxyRotation) * time.Second)
+	}
+
+	for _, acc := range m.accounts {
+		if acc.Proxy != "" || acc.proxy == nil {
+			continue
+		}
+		m.mu.Lock()
+		down := now.Before(acc.proxy.downUntil)
+		m.mu.Unlock()
+		if rotate || down {
+			m.rotateProxy(acc, m.proxyIndex(acc.proxy)+1)
+		}
+	}
+}
+
+func (m *Meerkat) proxyIndex(p *proxy) int {
+	for i, q := range m.proxies {
+		if q == p {
+			return i
+		}
+	}
+	return 0
+}
+
+// proxyDown takes p out of the pool for proxyCooldown.
+func (m *Meerkat) proxyDown(p *proxy, err error) {
+	m.mu.Lock()
+	p.downUntil = time.Now().Add(proxyCooldown)
+	p.lastError = err.Error()
+	m.mu.Unlock()
+
+	m.logger.Printf("Error, proxy %s is not used for %s, %s", proxyHost(p.url), proxyCooldown, err.Error())
+}
+
+// proxyResult keeps the health of the proxy of acc after a request,
+// after proxyFailures network errors in a row acc moves to another proxy.
+func (m *Meerkat) proxyResult(acc *account, err error) {
+	m.mu.Lock()
+	p := acc.proxy
+	if p == nil || acc.Proxy != "" {
+		m.mu.Unlock()
+		return
+	}
+	if classifyError(err) != errorTransient {
+		p.failures = 0
+		m.mu.Unlock()
+		return
+	}
+	p.failures++
+	failed := p.failures >= proxyFailures
+	if failed {
+		p.failures = 0
+	}
+	m.mu.Unlock()
+
+	if failed {
+		m.proxyDown(p, err)
+		m.rotateProxy(acc, m.proxyIndex(p)+1)
+	}
+}
+
+// proxiesStatus is the number of healthy proxies of the pool.
+func (m *Meerkat) proxiesStatus() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	healthy := 0
+	for _, p := range m.proxies {
+		if !now.Before(p.downUntil) {
+			healthy++
+		}
+	}
+	return fmt.Sprintf("%d of %d healthy", healthy, len(m.proxies))
+}
@@ -0,0 +1,91 @@
+package meerkat
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// testProxy is an HTTP proxy which answers every request with code after delay.
+func testProxy(code int, delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		w.WriteHeader(code)
+	}))
+}
+
+func proxyMeerkat(urls ...string) *Meerkat {
+	m := &Meerkat{logger: log.New(ioutil.Discard, "", 0)}
+	for _, url := range urls {
+		m.proxies = append(m.proxies, &proxy{url: url})
+	}
+	m.accounts = []*account{{}}
+	return m
+}
+
+func waitProxy(t *testing.T, m *Meerkat, p *proxy) {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		m.mu.Lock()
+		checking := p.checking
+		m.mu.Unlock()
+		if !checking {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("proxy check did not finish")
+}
+
+func TestRotateProxyDoesNotBlock(t *testing.T) {
+	slow := testProxy(http.StatusBadGateway, 500*time.Millisecond)
+	defer slow.Close()
+
+	m := proxyMeerkat(slow.URL, slow.URL+"/other")
+	acc := m.accounts[0]
+	start := time.Now()
+	m.rotateProxy(acc, 0)
+	m.rotateProxy(acc, 1)
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("rotation took %s", elapsed)
+	}
+	if acc.proxy != m.proxies[1] {
+		t.Errorf("account uses %s, want %s", acc.proxy.url, m.proxies[1].url)
+	}
+
+	// a second rotation to the same proxy does not start another check
+	m.rotateProxy(acc, 1)
+	waitProxy(t, m, m.proxies[0])
+	waitProxy(t, m, m.proxies[1])
+}
+
+func TestRotateProxiesLeavesDeadProxy(t *testing.T) {
+	dead := testProxy(http.StatusBadGateway, 0)
+	defer dead.Close()
+
+	m := proxyMeerkat(dead.URL, "http://127.0.0.1:1")
+	acc := m.accounts[0]
+	m.rotateProxy(acc, 0)
+	waitProxy(t, m, m.proxies[0])
+
+	m.mu.Lock()
+	down := !m.proxies[0].downUntil.IsZero()
+	m.mu.Unlock()
+	if !down {
+		t.Fatal("failing proxy is not down")
+	}
+
+	m.rotateProxies(time.Now())
+	if acc.proxy != m.proxies[1] {
+		t.Errorf("account uses %s, want %s", acc.proxy.url, m.proxies[1].url)
+	}
+	waitProxy(t, m, m.proxies[1])
+
+	// every proxy is down, the account keeps its proxy
+	m.rotateProxies(time.Now())
+	if acc.proxy != m.proxies[1] {
+		t.Errorf("account uses %s, want %s", acc.proxy.url, m.proxies[1].url)
+	}
+}
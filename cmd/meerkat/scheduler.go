@@ -0,0 +1,258 @@
+package meerkat
+
+import (
+	"time"
+)
+
+// TargetOptions overrides the polling of one target.
+type TargetOptions struct {
+	// Interval between checks of the target in seconds, default is interval.
+	Interval int
+	// Priority of the target, when several targets are due or the
+	// request budget is not enough, higher priorities are checked first.
+	Priority int
+}
+
+// The scheduler runs one job every sleeptime seconds, so requests are spread
+// over time instead of being sent in bursts. A job is either the following
+// activity feed (every interval seconds) or the check of the most overdue target.
+// Jobs are skipped while the requests of the last hour exceed requestsperhour.
+
+// slot is the time between two jobs.
+func (m *Meerkat) slot() time.Duration {
+	if m.SleepTime < 1 {
+		return time.Second
+	}
+	return time.Duration(m.SleepTime) * time.Second
+}
+
+// targetInterval is the time between checks of username.
+func (m *Meerkat) targetInterval(username string) time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if options, ok := m.TargetOptions[username]; ok && options.Interval > 0 {
+		return time.Duration(options.Interval) * time.Second
+	}
+	return time.Duration(m.Interval) * time.Second
+}
+
+// activityCost is the estimated number of requests of the activity job,
+// it is sent by each available account.
+func (m *Meerkat) activityCost() int {
+	cost := 1
+	if m.WatchStories {
+		cost++
+	}
+	if accounts := len(m.availableAccounts()); accounts > 1 {
+		cost *= accounts
+	}
+	return cost
+}
+
+// targetCost is the estimated number of requests of a target check,
+// pages of following lists are spent one by one when they are fetched.
+func (m *Meerkat) targetCost() int {
+	cost := 1
+	if m.WatchPosts {
+		cost++
+	}
+	if m.WatchStories {
+		cost++
+	}
+	if m.WatchEngagement > 0 {
+		cost += 1 + m.WatchEngagement
+	}
+	return cost + m.WatchComments
+}
+
+// requestsNeeded is the estimated requests per hour of the configured targets.
+func (m *Meerkat) requestsNeeded() int {
+	hour := float64(time.Hour)
+	needed := hour / float64(time.Duration(m.Interval)*time.Second) * float64(m.activityCost())
+	for _, username := range m.targets() {
+		needed += hour / float64(m.targetInterval(username)) * float64(m.targetCost())
+	}
+	return int(needed + 0.5)
+}
+
+// jobsNeeded is the number of jobs per hour of the configured targets.
+func (m *Meerkat) jobsNeeded() int {
+	hour := float64(time.Hour)
+	needed := hour / float64(time.Duration(m.Interval)*time.Second)
+	for _, username := range m.targets() {
+		needed += hour / float64(m.targetInterval(username))
+	}
+	return int(needed + 0.5)
+}
+
+// spend reserves cost requests from the hourly budget.
+func (m *Meerkat) spend(cost int) bool {
+	if m.RequestsPerHour <= 0 {
+		return true
+	}
+
+	now := time.Now()
+	requests := m.requests[:0]
+	for _, t := range m.requests {
+		if now.Sub(t) < time.Hour {
+			requests = append(requests, t)
+		}
+	}
+	m.requests = requests
+
+	if len(m.requests)+cost > m.RequestsPerHour {
+		return false
+	}
+	for i := 0; i < cost; i++ {
+		m.requests = append(m.requests, now)
+	}
+	return true
+}
+
+// checkBudget reports when requestsperhour is lower than what targets need,
+// and when there are not enough sleeptime slots to check targets in their interval.
+func (m *Meerkat) checkBudget() {
+	if slots := int(time.Hour / m.slot()); m.jobsNeeded() > slots && m.jobsNeeded() != m.slotsWarning {
+		m.slotsWarning = m.jobsNeeded()
+		m.logger.Printf("Targets need about %d checks per hour but sleeptime allows %d, they are checked less often than their interval", m.jobsNeeded(), slots)
+	}
+
+	if m.RequestsPerHour <= 0 {
+		return
+	}
+
+	needed := 0
+	if m.requestsNeeded() > m.RequestsPerHour {
+		needed = m.requestsNeeded()
+	}
+	if needed == m.budgetWarning {
+		return
+	}
+	m.budgetWarning = needed
+	if needed == 0 {
+		return
+	}
+
+	m.logger.Printf("Targets need about %d requests per hour but requestsperhour is %d", needed, m.RequestsPerHour)
+	m.send(&Event{
+		Kind:      EventBudget,
+		Field:     "requests per hour",
+		OldValue:  m.RequestsPerHour,
+		NewValue:  needed,
+		Timestamp: time.Now(),
+	})
+}
+
+// nextTarget returns the due target with the highest priority,
+// and the most overdue one between targets of the same priority.
+func (m *Meerkat) nextTarget(now time.Time) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	next := ""
+	var nextDue time.Time
+	for _, username := range m.TargetUsers {
+		due := m.schedule[username]
+		if h, ok := m.health[username]; ok && h.NextCheck.After(due) {
+			due = h.NextCheck
+		}
+		if due.After(now) {
+			continue
+		}
+
+		if next != "" {
+			priority, nextPriority := m.TargetOptions[username].Priority, m.TargetOptions[next].Priority
+			if priority < nextPriority || (priority == nextPriority && !due.Before(nextDue)) {
+				continue
+			}
+		}
+		next, nextDue = username, due
+	}
+	return next
+}
+
+// targetsResolved reports if every target has a snapshot,
+// or has failed, so it is not waited for anymore.
+func (m *Meerkat) targetsResolved() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	known := make(map[string]bool)
+	for _, user := range m.targetUsers {
+		known[user.Username] = true
+	}
+	for _, username := range m.TargetUsers {
+		if h, ok := m.health[username]; ok && h.Failures > 0 {
+			continue
+		}
+		if !known[username] {
+			return false
+		}
+	}
+	return true
+}
+
+// runJob runs the next due job, if any.
+func (m *Meerkat) runJob() {
+	now := time.Now()
+
+	m.reviveAccounts()
+	m.rotateProxies(now)
+	if len(m.availableAccounts()) == 0 {
+		return
+	}
+
+	m.mu.Lock()
+	activityDue := m.pollActivity || !now.Before(m.nextActivity)
+	m.mu.Unlock()
+
+	// activities about a target without snapshot can not be matched with its ID,
+	// and they would be marked seen, so targets are checked first
+	if activityDue && !m.targetsResolved() && m.nextTarget(now) != "" {
+		activityDue = false
+	}
+
+	if activityDue {
+		if !m.spend(m.activityCost()) {
+			return
+		}
+		m.mu.Lock()
+		m.pollActivity = false
+		m.mu.Unlock()
+		m.nextActivity = now.Add(time.Duration(m.Interval) * time.Second)
+
+		// each account only sees the activities of users it follows
+		for _, acc := range m.availableAccounts() {
+			if _, err := m.tryAccount(acc, m.checkActivity); err != nil {
+				m.logger.Printf("Error, activities of %s, %s", acc.Username, err.Error())
+			}
+		}
+
+		m.loadReelsTray()
+		m.checkBudget()
+
+		m.mu.Lock()
+		m.lastTick = now
+		m.mu.Unlock()
+	} else {
+		username := m.nextTarget(now)
+		if username == "" {
+			return
+		}
+		if !m.spend(m.targetCost()) {
+			return
+		}
+
+		next := now.Add(m.targetInterval(username))
+		m.mu.Lock()
+		m.schedule[username] = next
+		m.mu.Unlock()
+
+		m.checkTarget(username)
+	}
+
+	if err := m.saveState(); err != nil {
+		m.logger.Println("Error", err)
+	}
+}
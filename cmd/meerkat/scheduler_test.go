@@ -0,0 +1,114 @@
+package meerkat
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextTarget(t *testing.T) {
+	m := testMeerkat(t, testConfig)
+	m.TargetUsers = []string{"low", "high", "high2", "later", "failing"}
+	m.TargetOptions = map[string]TargetOptions{
+		"high":    {Priority: 10},
+		"high2":   {Priority: 10},
+		"later":   {Priority: 20},
+		"failing": {Priority: 30},
+	}
+
+	now := time.Now()
+	m.schedule["low"] = now.Add(-time.Hour)
+	m.schedule["high"] = now.Add(-time.Minute)
+	m.schedule["high2"] = now.Add(-2 * time.Minute)
+	m.schedule["later"] = now.Add(time.Minute)
+	m.health["failing"] = &targetHealth{Failures: 1, NextCheck: now.Add(time.Minute)}
+
+	// the most overdue of the highest priority, not due targets are skipped
+	order := []string{"high2", "high", "low", ""}
+	for _, want := range order {
+		got := m.nextTarget(now)
+		if got != want {
+			t.Fatalf("next target is %q, want %q", got, want)
+		}
+		m.schedule[got] = now.Add(time.Hour)
+	}
+
+	// the failing target is due after its backoff
+	if got := m.nextTarget(now.Add(2 * time.Minute)); got != "failing" {
+		t.Errorf("next target is %q, want failing", got)
+	}
+}
+
+func TestNextTargetNeverChecked(t *testing.T) {
+	m := testMeerkat(t, testConfig)
+	if got := m.nextTarget(time.Now()); got != "bob" {
+		t.Errorf("next target is %q, want bob for its priority", got)
+	}
+}
+
+func TestTargetInterval(t *testing.T) {
+	m := testMeerkat(t, testConfig)
+	if got := m.targetInterval("bob"); got != time.Minute {
+		t.Errorf("interval of bob is %s, want 1m", got)
+	}
+	if got := m.targetInterval("alice"); got != 15*time.Second {
+		t.Errorf("interval of alice is %s, want 15s", got)
+	}
+}
+
+func TestSpend(t *testing.T) {
+	m := testMeerkat(t, testConfig)
+	if !m.spend(1000) {
+		t.Fatal("spend without requestsperhour is refused")
+	}
+	if len(m.requests) != 0 {
+		t.Errorf("%d requests are recorded without requestsperhour", len(m.requests))
+	}
+
+	m.RequestsPerHour = 5
+	now := time.Now()
+	m.requests = []time.Time{
+		now.Add(-2 * time.Hour),
+		now.Add(-61 * time.Minute),
+		now.Add(-time.Hour - time.Second),
+		now.Add(-59 * time.Minute),
+		now.Add(-time.Minute),
+	}
+
+	// requests older than an hour do not count
+	if !m.spend(3) {
+		t.Fatal("spend within the budget is refused")
+	}
+	if len(m.requests) != 5 {
+		t.Errorf("%d requests are kept, want 5", len(m.requests))
+	}
+	if m.spend(1) {
+		t.Error("spend over the budget is accepted")
+	}
+	if len(m.requests) != 5 {
+		t.Errorf("refused spend recorded requests, %d", len(m.requests))
+	}
+}
+
+func TestTargetsResolved(t *testing.T) {
+	m := testMeerkat(t, testConfig)
+	if m.targetsResolved() {
+		t.Fatal("targets without snapshots are resolved")
+	}
+
+	m.targetUsers[1] = User{Username: "bob"}
+	if m.targetsResolved() {
+		t.Fatal("alice has no snapshot")
+	}
+
+	// a failing target is not waited for
+	m.health["alice"] = &targetHealth{Failures: 1}
+	if !m.targetsResolved() {
+		t.Fatal("failing target is waited for")
+	}
+
+	delete(m.health, "alice")
+	m.targetUsers[2] = User{Username: "alice"}
+	if !m.targetsResolved() {
+		t.Error("targets with snapshots are not resolved")
+	}
+}
@@ -1,8 +1,109 @@
 package meerkat
 
+import (
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+)
+
+// Sender is an output of meerkat, such as logfile or telegram.
+// Each event produced by the watcher is passed to all configured senders.
 type Sender interface {
-	Send(to interface{}, message string) error
+	Send(event *Event) error
+}
+
+// senderFactory creates a Sender using meerkat configuration.
+type senderFactory func(m *Meerkat) (Sender, error)
+
+var senderFactories = make(map[string]senderFactory)
+
+// registerSender makes an output available in outputtype by name.
+// Outputs call it from their init function.
+func registerSender(name string, factory senderFactory) {
+	senderFactories[name] = factory
+}
+
+func senderNames() []string {
+	names := make([]string, 0, len(senderFactories))
+	for name := range senderFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (m *Meerkat) loadSenders() error {
+	m.senders = nil
+	for _, name := range strings.Split(m.OutputType, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		factory, ok := senderFactories[name]
+		if !ok {
+			return fmt.Errorf("Unknown outputtype %s, choose from %v", name, senderNames())
+		}
+		sender, err := factory(m)
+		if err != nil {
+			return fmt.Errorf("%s output, %s", name, err.Error())
+		}
+		m.senders = append(m.senders, sender)
+	}
+
+	if len(m.senders) == 0 {
+		return fmt.Errorf("Fill outputtype with %v", senderNames())
+	}
+	return nil
+}
+
+// send delivers event to every configured output.
+// Failure of an output does not prevent delivery to the others.
+func (m *Meerkat) send(event *Event) {
+	m.recordEvent(event)
+	for _, sender := range m.senders {
+		if err := sender.Send(event); err != nil {
+			m.logger.Println("Error", err)
+		}
+	}
+}
+
+// closeSenders closes the outputs which deliver in background,
+// so their pending events are not lost on exit.
+func (m *Meerkat) closeSenders() error {
+	var err error
+	for _, sender := range m.senders {
+		if closer, ok := sender.(io.Closer); ok {
+			if closeErr := closer.Close(); err == nil {
+				err = closeErr
+			}
+		}
+	}
+	return err
 }
 
-// TODO : user Sender interface for send messages to users.
-// For example , implement sender interface for telegram.
+type logSender struct {
+	logger   *log.Logger
+	renderer *renderer
+}
+
+func init() {
+	registerSender("logfile", func(m *Meerkat) (Sender, error) {
+		r, err := m.newRenderer("logfile")
+		if err != nil {
+			return nil, err
+		}
+		return &logSender{logger: m.logger, renderer: r}, nil
+	})
+}
+
+func (s *logSender) Send(event *Event) error {
+	message, err := s.renderer.render(event)
+	if err != nil {
+		return err
+	}
+	s.logger.Println(message)
+	return nil
+}
@@ -0,0 +1,91 @@
+package meerkat
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/ahmdrz/goinsta"
+	"github.com/ahmdrz/goinsta/store"
+)
+
+// sessionKey returns the AES key of the session file of acc.
+// It is derived from the account credentials, so the session of an account
+// can not be reused with another configuration.
+func sessionKey(acc *account) []byte {
+	key := sha256.Sum256([]byte(acc.Username + ":" + acc.Password))
+	return key[:]
+}
+
+// loginAccount reuses the session stored in the session file of acc,
+// and only logs in again when there is no session or Instagram rejects it.
+func (m *Meerkat) loginAccount(acc *account) error {
+	insta, err := importSession(acc)
+	if err != nil {
+		m.logger.Println("Could not reuse the session,", err)
+	}
+
+	if insta != nil {
+		insta.Proxy = m.proxyURL(acc)
+		_, err = insta.GetProfileData()
+		if err == nil {
+			acc.instagram = insta
+			m.setLogin(acc, true)
+			m.logger.Println("Session has been restored from", acc.sessionFile)
+			return nil
+		}
+		if err != goinsta.ErrLoggedOut {
+			return fmt.Errorf("Instagram error , %s", err.Error())
+		}
+		m.logger.Printf("Session of %s is logged out, logging in again", acc.Username)
+	}
+
+	return m.relogin(acc)
+}
+
+// relogin logs in acc with username and password, and saves the new session.
+func (m *Meerkat) relogin(acc *account) error {
+	m.logger.Printf("Logging in to the Instagram as %s", acc.Username)
+
+	insta := goinsta.New(acc.Username, acc.Password)
+	if proxy := m.proxyURL(acc); proxy != "" {
+		insta = goinsta.NewViaProxy(acc.Username, acc.Password, proxy)
+	}
+	if err := insta.Login(); err != nil {
+		m.setLogin(acc, false)
+		return fmt.Errorf("Instagram error , %s", err.Error())
+	}
+	acc.instagram = insta
+	m.setLogin(acc, true)
+
+	if err := exportSession(acc); err != nil {
+		m.logger.Println("Could not save the session,", err)
+	}
+	return nil
+}
+
+func (m *Meerkat) setLogin(acc *account, login bool) {
+	m.mu.Lock()
+	acc.login = login
+	m.mu.Unlock()
+}
+
+func importSession(acc *account) (*goinsta.Instagram, error) {
+	bytes, err := ioutil.ReadFile(acc.sessionFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return store.Import(bytes, sessionKey(acc))
+}
+
+func exportSession(acc *account) error {
+	bytes, err := store.Export(acc.instagram, sessionKey(acc))
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(acc.sessionFile, bytes, 0600)
+}
@@ -0,0 +1,63 @@
+package meerkat
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+)
+
+// state is the part of meerkat which survives restarts.
+// It is stored as JSON in statefile.
+type state struct {
+	// SeenStories are the keys of reported activity stories, oldest first.
+	SeenStories []string
+	Users       map[int64]User
+}
+
+// loadState restores the watcher from statefile.
+// A missing statefile means meerkat starts from scratch.
+func (m *Meerkat) loadState() error {
+	bytes, err := ioutil.ReadFile(m.StateFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	s := state{}
+	if err := json.Unmarshal(bytes, &s); err != nil {
+		return err
+	}
+
+	for _, key := range s.SeenStories {
+		m.markSeen(key)
+	}
+	for id, user := range s.Users {
+		// skip users which are not targets anymore
+		for _, username := range m.TargetUsers {
+			if user.Username == username {
+				m.targetUsers[id] = user
+				break
+			}
+		}
+	}
+
+	m.logger.Printf("State of %d users has been loaded from %s", len(m.targetUsers), m.StateFile)
+	return nil
+}
+
+// saveState checkpoints the watcher into statefile.
+// It writes to a temporary file first, so a crash never leaves a broken statefile.
+func (m *Meerkat) saveState() error {
+	s := state{}
+	m.mu.Lock()
+	s.SeenStories, s.Users = m.seenOrder, m.targetUsers
+	bytes, err := json.MarshalIndent(s, "", "  ")
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(m.StateFile, bytes)
+}
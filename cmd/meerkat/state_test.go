@@ -0,0 +1,76 @@
+package meerkat
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestMarkSeenEviction(t *testing.T) {
+	m := testMeerkat(t, testConfig)
+	for i := 0; i < seenStories; i++ {
+		if !m.markSeen(fmt.Sprint(i)) {
+			t.Fatalf("story %d is seen before", i)
+		}
+	}
+	if m.markSeen("0") {
+		t.Fatal("seen story is reported again")
+	}
+
+	// one more story forgets the oldest one
+	if !m.markSeen("new") {
+		t.Fatal("new story is seen before")
+	}
+	if len(m.seenOrder) != seenStories || len(m.seen) != seenStories {
+		t.Errorf("%d and %d stories are kept, want %d", len(m.seenOrder), len(m.seen), seenStories)
+	}
+	if m.seen["0"] || !m.seen["1"] || m.seenOrder[0] != "1" {
+		t.Errorf("oldest kept story is %s", m.seenOrder[0])
+	}
+	if !m.markSeen("0") {
+		t.Error("forgotten story is still seen")
+	}
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	m := testMeerkat(t, testConfig)
+	m.StateFile = filepath.Join(filepath.Dir(m.configFile), "meerkat.state")
+	m.targetUsers[1] = User{Username: "bob", Followers: 10}
+	m.targetUsers[2] = User{Username: "carol"}
+	m.markSeen("1")
+	m.markSeen("2")
+	if err := m.saveState(); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := testMeerkat(t, testConfig)
+	restarted.StateFile = m.StateFile
+	if err := restarted.loadState(); err != nil {
+		t.Fatal(err)
+	}
+
+	if restarted.markSeen("1") || restarted.markSeen("2") {
+		t.Error("stories seen before the restart are reported again")
+	}
+	if !restarted.markSeen("3") {
+		t.Error("new story is seen before")
+	}
+	if user := restarted.targetUsers[1]; user.Username != "bob" || user.Followers != 10 {
+		t.Errorf("snapshot of bob is %v", user)
+	}
+	// carol is not a target anymore
+	if _, ok := restarted.targetUsers[2]; ok {
+		t.Error("snapshot of a removed target is loaded")
+	}
+}
+
+func TestLoadStateMissing(t *testing.T) {
+	m := testMeerkat(t, testConfig)
+	m.StateFile = filepath.Join(filepath.Dir(m.configFile), "missing.state")
+	if err := m.loadState(); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.targetUsers) != 0 || len(m.seenOrder) != 0 {
+		t.Error("state is loaded from a missing statefile")
+	}
+}
@@ -0,0 +1,97 @@
+package meerkat
+
+import (
+	"fmt"
+	"time"
+)
+
+func storyURL(username string, pk int64) string {
+	return fmt.Sprintf("https://www.instagram.com/stories/%s/%d/", username, pk)
+}
+
+// loadReelsTray fetches the stories trays of accounts once per tick, so targets
+// followed by a watcher account are only checked when they have a new story.
+func (m *Meerkat) loadReelsTray() {
+	m.reelsTray = nil
+	if !m.WatchStories {
+		return
+	}
+
+	m.reelsTray = make(map[int64]int64)
+	for _, acc := range m.availableAccounts() {
+		tray, err := acc.instagram.GetReelsTrayFeed()
+		if err != nil {
+			m.logger.Printf("Error, stories tray of %s, %s", acc.Username, err.Error())
+			continue
+		}
+		for _, reel := range tray.Tray {
+			if latest := int64(reel.LatestReelMedia); latest > m.reelsTray[int64(reel.User.Pk)] {
+				m.reelsTray[int64(reel.User.Pk)] = latest
+			}
+		}
+	}
+}
+
+// checkStories reports new story items of a target when watchstories is enabled,
+// and archives them before they expire when archivedir is set.
+func (m *Meerkat) checkStories(userID int64, user *User) error {
+	if !m.WatchStories {
+		return nil
+	}
+	if latest, ok := m.reelsTray[userID]; ok && latest <= user.LastStoryAt && !user.StoriesCheckedAt.IsZero() {
+		return nil
+	}
+
+	stories, err := m.instagram.GetUserStories(userID)
+	if err != nil {
+		return err
+	}
+
+	lastStoryAt := user.LastStoryAt
+	for _, item := range stories.Reel.Items {
+		takenAt := int64(item.TakenAt)
+		if takenAt > lastStoryAt {
+			lastStoryAt = takenAt
+		}
+		// the first stories are only stored
+		if user.StoriesCheckedAt.IsZero() || takenAt <= user.LastStoryAt {
+			continue
+		}
+
+		media := &Media{
+			ID:      item.ID,
+			URL:     storyURL(user.Username, item.Pk),
+			Type:    mediaTypeName(item.MediaType),
+			TakenAt: time.Unix(takenAt, 0),
+		}
+
+		if m.ArchiveDir != "" {
+			url := ""
+			if item.MediaType == mediaVideo && len(item.VideoVersions) > 0 {
+				url = item.VideoVersions[0].URL
+			} else if len(item.ImageVersions2.Candidates) > 0 {
+				url = item.ImageVersions2.Candidates[0].URL
+			}
+			if url != "" {
+				file, err := m.archive(user.Username, fmt.Sprintf("story_%d", item.Pk), url)
+				if err != nil {
+					m.logger.Println("Error", err)
+				} else {
+					media.Files = append(media.Files, file)
+				}
+			}
+		}
+
+		m.send(&Event{
+			Kind:      EventStory,
+			UserID:    userID,
+			Username:  user.Username,
+			Media:     media,
+			Timestamp: media.TakenAt,
+		})
+	}
+
+	user.LastStoryAt = lastStoryAt
+	user.StoriesCheckedAt = time.Now()
+	return nil
+}
@@ -0,0 +1,158 @@
+package meerkat
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Targets can be changed while meerkat is running (by bot commands),
+// so TargetUsers, TargetOptions, targetUsers, health, schedule and paused are guarded by m.mu.
+
+// targets returns a copy of the target usernames.
+func (m *Meerkat) targets() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.TargetUsers...)
+}
+
+func (m *Meerkat) isTarget(username string) bool {
+	for _, target := range m.TargetUsers {
+		if target == username {
+			return true
+		}
+	}
+	return false
+}
+
+// targetUser returns the last snapshot of the target with userID.
+func (m *Meerkat) targetUser(userID int64) (User, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user, ok := m.targetUsers[userID]
+	return user, ok
+}
+
+// targetByUsername returns the last snapshot of username.
+func (m *Meerkat) targetByUsername(username string) (int64, User, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, user := range m.targetUsers {
+		if user.Username == username {
+			return id, user, true
+		}
+	}
+	return 0, User{}, false
+}
+
+// setTargetUser stores the snapshot of a user,
+// unless it has been removed from targets in the meantime.
+func (m *Meerkat) setTargetUser(userID int64, user User) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.isTarget(user.Username) {
+		m.targetUsers[userID] = user
+	}
+}
+
+// addTarget adds username to targets and saves the configuration.
+// Its first snapshot is taken on the next tick.
+func (m *Meerkat) addTarget(username string) error {
+	username = strings.TrimPrefix(strings.TrimSpace(username), "@")
+	if username == "" {
+		return fmt.Errorf("username is empty")
+	}
+
+	m.mu.Lock()
+	if m.isTarget(username) {
+		m.mu.Unlock()
+		return fmt.Errorf("%s is already a target", username)
+	}
+	m.TargetUsers = append(m.TargetUsers, username)
+	m.mu.Unlock()
+
+	return m.saveConfig()
+}
+
+// removeTarget removes username and its snapshot from targets
+// and saves the configuration.
+func (m *Meerkat) removeTarget(username string) error {
+	username = strings.TrimPrefix(strings.TrimSpace(username), "@")
+
+	m.mu.Lock()
+	if !m.isTarget(username) {
+		m.mu.Unlock()
+		return fmt.Errorf("%s is not a target", username)
+	}
+	targets := m.TargetUsers[:0:0]
+	for _, target := range m.TargetUsers {
+		if target != username {
+			targets = append(targets, target)
+		}
+	}
+	m.TargetUsers = targets
+	for id, user := range m.targetUsers {
+		if user.Username == username {
+			delete(m.targetUsers, id)
+		}
+	}
+	delete(m.health, username)
+	delete(m.schedule, username)
+	m.mu.Unlock()
+
+	return m.saveConfig()
+}
+
+// renameTarget replaces oldUsername with newUsername in targets, its snapshot
+// and the options and telegram routes of the target, and saves the configuration.
+func (m *Meerkat) renameTarget(oldUsername, newUsername string) error {
+	m.mu.Lock()
+	for i, target := range m.TargetUsers {
+		if target == oldUsername {
+			m.TargetUsers[i] = newUsername
+		}
+	}
+	for id, user := range m.targetUsers {
+		if user.Username == oldUsername {
+			user.Username = newUsername
+			m.targetUsers[id] = user
+		}
+	}
+	if h, ok := m.health[oldUsername]; ok {
+		m.health[newUsername] = h
+		delete(m.health, oldUsername)
+	}
+	if next, ok := m.schedule[oldUsername]; ok {
+		m.schedule[newUsername] = next
+		delete(m.schedule, oldUsername)
+	}
+	if options, ok := m.TargetOptions[oldUsername]; ok {
+		m.TargetOptions[newUsername] = options
+		delete(m.TargetOptions, oldUsername)
+	}
+	for _, route := range m.TelegramRoutes {
+		for i, target := range route.Targets {
+			if target == oldUsername {
+				route.Targets[i] = newUsername
+			}
+		}
+	}
+	m.mu.Unlock()
+
+	return m.editConfig(func(lines []string) []string {
+		lines = m.replaceTargets(lines)
+		lines = renameInBlock(lines, "targetoptions", oldUsername, newUsername)
+		return renameInBlock(lines, "telegramroutes", oldUsername, newUsername)
+	})
+}
+
+func (m *Meerkat) setPaused(paused bool) {
+	m.mu.Lock()
+	m.paused = paused
+	m.mu.Unlock()
+}
+
+func (m *Meerkat) isPaused() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.paused
+}
@@ -0,0 +1,276 @@
+package meerkat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	telegramAPIURL = "https://api.telegram.org"
+
+	// telegramMaxLength is the maximum length of a message text.
+	telegramMaxLength = 4096
+	// telegramMaxRetries is the number of retries after 429 responses.
+	telegramMaxRetries = 3
+)
+
+// telegramClient is a minimal client of Telegram bot API.
+type telegramClient struct {
+	apiURL string
+	token  string
+	client *http.Client
+	// retryUnit is the unit of retry_after, one second in Telegram API.
+	retryUnit time.Duration
+}
+
+type telegramResponse struct {
+	Ok          bool            `json:"ok"`
+	ErrorCode   int             `json:"error_code"`
+	Description string          `json:"description"`
+	Result      json.RawMessage `json:"result"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+func newTelegramClient(apiURL, token string) *telegramClient {
+	if apiURL == "" {
+		apiURL = telegramAPIURL
+	}
+	return &telegramClient{
+		apiURL:    strings.TrimRight(apiURL, "/"),
+		token:     token,
+		client:    &http.Client{Timeout: 60 * time.Second},
+		retryUnit: time.Second,
+	}
+}
+
+// call posts params as JSON to method, and decodes the result into result.
+// When Telegram rate limits the bot, call waits retry_after seconds and tries again.
+func (c *telegramClient) call(method string, params interface{}, result interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		output, err := c.post(method, body)
+		if err != nil {
+			return err
+		}
+
+		if output.Ok {
+			if result == nil {
+				return nil
+			}
+			return json.Unmarshal(output.Result, result)
+		}
+
+		if output.ErrorCode == http.StatusTooManyRequests && attempt < telegramMaxRetries {
+			time.Sleep(time.Duration(output.Parameters.RetryAfter+1) * c.retryUnit)
+			continue
+		}
+		return fmt.Errorf("Telegram bot %s", output.Description)
+	}
+}
+
+func (c *telegramClient) post(method string, body []byte) (*telegramResponse, error) {
+	url := fmt.Sprintf("%s/bot%s/%s", c.apiURL, c.token, method)
+	resp, err := c.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		// error contains the url, do not leak the token in logs
+		return nil, fmt.Errorf("Telegram bot %s request failed", method)
+	}
+	defer resp.Body.Close()
+
+	bytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &telegramResponse{}
+	if err := json.Unmarshal(bytes, output); err != nil {
+		return nil, fmt.Errorf("Telegram bot invalid response, status code %d", resp.StatusCode)
+	}
+	return output, nil
+}
+
+// sendMessage sends text to chat, texts longer than Telegram limit
+// are split into several messages.
+func (c *telegramClient) sendMessage(chatID int64, text, parseMode string) error {
+	for _, part := range splitMessage(text, telegramMaxLength) {
+		params := map[string]interface{}{
+			"chat_id": chatID,
+			"text":    part,
+		}
+		if parseMode != "" {
+			params["parse_mode"] = parseMode
+		}
+		if err := c.call("sendMessage", params, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitMessage splits text into parts of at most limit characters,
+// on line breaks when it is possible. Escaped text is never split
+// between a backslash and the escaped character, nor inside an HTML entity.
+func splitMessage(text string, limit int) []string {
+	runes := []rune(text)
+	parts := []string{}
+	for len(runes) > limit {
+		cut := limit
+		for i := limit - 1; i > limit/2; i-- {
+			if runes[i] == '\n' {
+				cut = i + 1
+				break
+			}
+		}
+		cut = escapedCut(runes, cut)
+		parts = append(parts, string(runes[:cut]))
+		runes = runes[cut:]
+	}
+	return append(parts, string(runes))
+}
+
+// maxEntityLength is the length of the longest HTML entity escapeTelegram writes.
+const maxEntityLength = len("&amp;")
+
+// escapedCut moves cut before an escape sequence which it would split.
+func escapedCut(runes []rune, cut int) int {
+	safe := cut
+	for i := cut - 1; i >= 0 && i > cut-maxEntityLength; i-- {
+		if runes[i] == ';' {
+			break
+		}
+		if runes[i] == '&' {
+			safe = i
+			break
+		}
+	}
+
+	backslashes := 0
+	for i := safe - 1; i >= 0 && runes[i] == '\\'; i-- {
+		backslashes++
+	}
+	if backslashes%2 == 1 {
+		safe--
+	}
+
+	if safe <= 0 {
+		return cut
+	}
+	return safe
+}
+
+var (
+	markdownEscaper = strings.NewReplacer("_", "\\_", "*", "\\*", "`", "\\`", "[", "\\[")
+	htmlEscaper     = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+)
+
+// escapeTelegram escapes text for parseMode.
+func escapeTelegram(text, parseMode string) string {
+	switch strings.ToLower(parseMode) {
+	case "markdown":
+		return markdownEscaper.Replace(text)
+	case "html":
+		return htmlEscaper.Replace(text)
+	}
+	return text
+}
+
+// TelegramRoute sends events of targets only to chats.
+type TelegramRoute struct {
+	Targets []string
+	Chats   []int64
+}
+
+type telegramSender struct {
+	client    *telegramClient
+	chats     []int64
+	routes    []TelegramRoute
+	parseMode string
+	renderer  *renderer
+}
+
+func init() {
+	registerSender("telegram", func(m *Meerkat) (Sender, error) {
+		if m.TelegramToken == "" {
+			return nil, fmt.Errorf("telegramtoken is empty")
+		}
+		switch strings.ToLower(m.TelegramParseMode) {
+		case "", "markdown", "html":
+		default:
+			return nil, fmt.Errorf("telegramparsemode must be Markdown or HTML")
+		}
+		chats := m.TelegramChats
+		if m.TelegramUser != 0 {
+			chats = append([]int64{int64(m.TelegramUser)}, chats...)
+		}
+		if len(chats) == 0 {
+			// events of unrouted targets and of accounts have no other chat
+			return nil, fmt.Errorf("fill telegramuser or telegramchats")
+		}
+		r, err := m.newRenderer("telegram")
+		if err != nil {
+			return nil, err
+		}
+		return &telegramSender{
+			client:    newTelegramClient(m.TelegramAPIURL, m.TelegramToken),
+			chats:     chats,
+			routes:    m.TelegramRoutes,
+			parseMode: m.TelegramParseMode,
+			renderer:  r,
+		}, nil
+	})
+}
+
+// recipients returns chats of username, targets which are not
+// in any route are sent to the default chats.
+func (s *telegramSender) recipients(username string) []int64 {
+	var chats []int64
+	routed := false
+	for _, route := range s.routes {
+		for _, target := range route.Targets {
+			if target == username {
+				chats = append(chats, route.Chats...)
+				routed = true
+				break
+			}
+		}
+	}
+	if !routed {
+		return s.chats
+	}
+	return chats
+}
+
+func (s *telegramSender) Send(event *Event) error {
+	message, err := s.renderer.render(event)
+	if err != nil {
+		return err
+	}
+	if !s.renderer.hasTemplate(event.Kind) {
+		// built-in messages are plain text
+		message = escapeTelegram(message, s.parseMode)
+	}
+
+	sent := make(map[int64]bool)
+	var lastErr error
+	for _, chat := range s.recipients(event.Username) {
+		if sent[chat] {
+			continue
+		}
+		sent[chat] = true
+		if err := s.client.sendMessage(chat, message, s.parseMode); err != nil {
+			lastErr = fmt.Errorf("chat %d, %s", chat, err.Error())
+		}
+	}
+	return lastErr
+}
@@ -0,0 +1,247 @@
+package meerkat
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// telegramServer is a fake Telegram bot API. It rate limits the first
+// limited requests, and records the parameters of the other requests.
+type telegramServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	limited  int
+	requests int
+	paths    []string
+	messages []map[string]interface{}
+}
+
+func newTelegramServer(limited int) *telegramServer {
+	s := &telegramServer{limited: limited}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&params)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.requests++
+		if s.requests <= s.limited {
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 1","parameters":{"retry_after":1}}`)
+			return
+		}
+		s.paths = append(s.paths, r.URL.Path)
+		s.messages = append(s.messages, params)
+		fmt.Fprint(w, `{"ok":true,"result":{"message_id":1}}`)
+	}))
+	return s
+}
+
+func testTelegramClient(server *telegramServer) *telegramClient {
+	c := newTelegramClient(server.URL+"/", "token")
+	c.retryUnit = 10 * time.Millisecond
+	return c
+}
+
+func TestTelegramCall(t *testing.T) {
+	server := newTelegramServer(0)
+	defer server.Close()
+
+	result := struct {
+		MessageID int `json:"message_id"`
+	}{}
+	c := testTelegramClient(server)
+	if err := c.call("sendMessage", map[string]interface{}{"chat_id": 1, "text": "hi"}, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.MessageID != 1 {
+		t.Errorf("message ID is %d, want 1", result.MessageID)
+	}
+	if server.paths[0] != "/bottoken/sendMessage" {
+		t.Errorf("path is %s", server.paths[0])
+	}
+	if server.messages[0]["text"] != "hi" {
+		t.Errorf("params are %v", server.messages[0])
+	}
+}
+
+func TestTelegramCallRetryAfter(t *testing.T) {
+	server := newTelegramServer(2)
+	defer server.Close()
+
+	c := testTelegramClient(server)
+	start := time.Now()
+	if err := c.call("sendMessage", map[string]interface{}{"chat_id": 1, "text": "hi"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if server.requests != 3 {
+		t.Errorf("%d requests, want 3", server.requests)
+	}
+	// retry_after is 1, so each retry waits 2 units
+	if elapsed, want := time.Since(start), 4*c.retryUnit; elapsed < want {
+		t.Errorf("retried after %s, want at least %s", elapsed, want)
+	}
+}
+
+func TestTelegramCallRateLimited(t *testing.T) {
+	server := newTelegramServer(100)
+	defer server.Close()
+
+	c := testTelegramClient(server)
+	err := c.call("sendMessage", map[string]interface{}{"chat_id": 1, "text": "hi"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "Too Many Requests") {
+		t.Errorf("error is %v", err)
+	}
+	if server.requests != telegramMaxRetries+1 {
+		t.Errorf("%d requests, want %d", server.requests, telegramMaxRetries+1)
+	}
+}
+
+func TestTelegramCallHidesToken(t *testing.T) {
+	server := newTelegramServer(0)
+	server.Close()
+
+	c := testTelegramClient(server)
+	err := c.call("sendMessage", nil, nil)
+	if err == nil || strings.Contains(err.Error(), "token") {
+		t.Errorf("error is %v", err)
+	}
+}
+
+func TestTelegramSendMessage(t *testing.T) {
+	server := newTelegramServer(0)
+	defer server.Close()
+
+	c := testTelegramClient(server)
+	text := strings.Repeat("a", telegramMaxLength) + "b"
+	if err := c.sendMessage(7, text, "HTML"); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.messages) != 2 {
+		t.Fatalf("%d messages, want 2", len(server.messages))
+	}
+	for _, message := range server.messages {
+		if message["chat_id"] != float64(7) || message["parse_mode"] != "HTML" {
+			t.Errorf("params are %v", message)
+		}
+	}
+	if server.messages[1]["text"] != "b" {
+		t.Errorf("last part is %v", server.messages[1]["text"])
+	}
+}
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		text  string
+		limit int
+		want  []string
+	}{
+		{"", 10, []string{""}},
+		{"short", 10, []string{"short"}},
+		{"0123456789", 10, []string{"0123456789"}},
+		{"0123456789ab", 10, []string{"0123456789", "ab"}},
+		{"012345\n789abc", 10, []string{"012345\n", "789abc"}},
+		{"01\n3456789abc", 10, []string{"01\n3456789", "abc"}},
+		{"ааааабббббв", 5, []string{"ааааа", "ббббб", "в"}},
+		{"aaaaaaaaa\\_b", 10, []string{"aaaaaaaaa", "\\_b"}},
+		{"aaaaaaaa\\\\_b", 10, []string{"aaaaaaaa\\\\", "_b"}},
+		{"aaaaaaa&amp;b", 10, []string{"aaaaaaa", "&amp;b"}},
+		{"aaaaa&amp;bb", 10, []string{"aaaaa&amp;", "bb"}},
+		{"aaaaaaaa&lt;b", 10, []string{"aaaaaaaa", "&lt;b"}},
+	}
+
+	for _, test := range tests {
+		got := splitMessage(test.text, test.limit)
+		if strings.Join(got, "|") != strings.Join(test.want, "|") {
+			t.Errorf("split %q is %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestSplitEscapedMessage(t *testing.T) {
+	text := strings.Repeat("a_b*c<d>&e ", 1000)
+	for _, parseMode := range []string{"Markdown", "HTML"} {
+		escaped := escapeTelegram(text, parseMode)
+		parts := splitMessage(escaped, telegramMaxLength)
+		if strings.Join(parts, "") != escaped {
+			t.Errorf("%s parts do not make the message", parseMode)
+		}
+		for i, part := range parts {
+			if len([]rune(part)) > telegramMaxLength {
+				t.Errorf("%s part %d has %d characters", parseMode, i, len([]rune(part)))
+			}
+			trimmed := strings.TrimRight(part, "\\")
+			if (len(part)-len(trimmed))%2 == 1 {
+				t.Errorf("%s part %d ends with an escape", parseMode, i)
+			}
+			amp := strings.LastIndex(part, "&")
+			if parseMode == "HTML" && amp >= 0 && !strings.Contains(part[amp:], ";") {
+				t.Errorf("%s part %d ends inside an entity", parseMode, i)
+			}
+		}
+	}
+}
+
+func TestEscapeTelegram(t *testing.T) {
+	tests := []struct {
+		text      string
+		parseMode string
+		want      string
+	}{
+		{"a_b*c`d[e]", "Markdown", "a\\_b\\*c\\`d\\[e]"},
+		{"a_b*c", "markdown", "a\\_b\\*c"},
+		{"<b>&</b>", "HTML", "&lt;b&gt;&amp;&lt;/b&gt;"},
+		{"a_b <c>", "", "a_b <c>"},
+	}
+
+	for _, test := range tests {
+		if got := escapeTelegram(test.text, test.parseMode); got != test.want {
+			t.Errorf("escape %q in %q is %q, want %q", test.text, test.parseMode, got, test.want)
+		}
+	}
+}
+
+func TestTelegramRecipients(t *testing.T) {
+	s := &telegramSender{
+		chats: []int64{1},
+		routes: []TelegramRoute{
+			{Targets: []string{"alice"}, Chats: []int64{2}},
+			{Targets: []string{"alice", "bob"}, Chats: []int64{3}},
+		},
+	}
+
+	tests := []struct {
+		username string
+		want     string
+	}{
+		{"alice", "[2 3]"},
+		{"bob", "[3]"},
+		{"carol", "[1]"},
+	}
+	for _, test := range tests {
+		if got := fmt.Sprint(s.recipients(test.username)); got != test.want {
+			t.Errorf("recipients of %s are %s, want %s", test.username, got, test.want)
+		}
+	}
+}
+
+func TestTelegramSenderNeedsDefaultChats(t *testing.T) {
+	m := testMeerkat(t, testConfig)
+	m.TelegramToken = "token"
+	m.TelegramRoutes = []TelegramRoute{{Targets: []string{"bob"}, Chats: []int64{2}}}
+
+	if _, err := senderFactories["telegram"](m); err == nil {
+		t.Error("telegram output without default chats is accepted")
+	}
+
+	m.TelegramChats = []int64{1}
+	if _, err := senderFactories["telegram"](m); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,94 @@
+package meerkat
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+	"time"
+)
+
+// defaultTemplates is the key of templates shared by all outputs.
+const defaultTemplates = "default"
+
+// renderer turns events into messages of one output,
+// using the templates configured for it in meerkat.yaml.
+type renderer struct {
+	templates map[EventKind]*template.Template
+	location  *time.Location
+}
+
+// templateData is what templates can access,
+// the event fields and a few helpers.
+type templateData struct {
+	*Event
+	Time       time.Time
+	ProfileURL string
+}
+
+var templateFuncs = template.FuncMap{
+	"format": func(layout string, t time.Time) string {
+		return t.Format(layout)
+	},
+	"markdown": func(text interface{}) string {
+		return escapeTelegram(fmt.Sprint(text), "markdown")
+	},
+}
+
+func (m *Meerkat) newRenderer(output string) (*renderer, error) {
+	r := &renderer{
+		templates: make(map[EventKind]*template.Template),
+		location:  time.Local,
+	}
+
+	if m.Timezone != "" {
+		location, err := time.LoadLocation(m.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("timezone %s", err.Error())
+		}
+		r.location = location
+	}
+
+	// templates of the output override the default ones
+	for _, name := range []string{defaultTemplates, output} {
+		for kind, text := range m.Templates[name] {
+			tmpl, err := template.New(name + "." + kind).Funcs(templateFuncs).Parse(text)
+			if err != nil {
+				return nil, err
+			}
+			r.templates[EventKind(kind)] = tmpl
+		}
+	}
+	return r, nil
+}
+
+func (r *renderer) hasTemplate(kind EventKind) bool {
+	_, ok := r.templates[kind]
+	return ok
+}
+
+// render returns the message of event, events without template
+// are rendered using Event.String in the configured timezone.
+func (r *renderer) render(event *Event) (string, error) {
+	local := *event
+	local.Timestamp = event.Timestamp.In(r.location)
+
+	tmpl, ok := r.templates[event.Kind]
+	if !ok {
+		return local.String(), nil
+	}
+
+	buffer := &bytes.Buffer{}
+	err := tmpl.Execute(buffer, templateData{
+		Event:      &local,
+		Time:       local.Timestamp,
+		ProfileURL: profileURL(event.Username),
+	})
+	if err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+func profileURL(username string) string {
+	return "https://www.instagram.com/" + username + "/"
+}
@@ -0,0 +1,236 @@
+package meerkat
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// WebhookConfig is the configuration of webhook output.
+type WebhookConfig struct {
+	URLs       []string
+	Headers    map[string]string
+	Secret     string
+	Retries    int
+	DeadLetter string
+}
+
+const (
+	webhookSignatureHeader = "X-Meerkat-Signature"
+	webhookQueueSize       = 100
+	webhookRetryDelay      = time.Second
+	// webhookDrainTimeout is how long Close delivers the queued events.
+	webhookDrainTimeout = 10 * time.Second
+)
+
+// webhookSender posts events as JSON to the configured URLs.
+// Delivery happens in background, so a slow endpoint does not block the watcher.
+type webhookSender struct {
+	config       WebhookConfig
+	client       *http.Client
+	logger       *log.Logger
+	queue        chan *Event
+	retryDelay   time.Duration
+	drainTimeout time.Duration
+
+	// ctx is canceled drainTimeout after Close, closing stops retries
+	// and done is closed when the worker has finished the queue.
+	ctx     context.Context
+	cancel  context.CancelFunc
+	closing chan struct{}
+	done    chan struct{}
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// deadLetter is a line of the dead letter file.
+type deadLetter struct {
+	URL   string    `json:"url"`
+	Error string    `json:"error"`
+	Time  time.Time `json:"time"`
+	Event *Event    `json:"event"`
+}
+
+func init() {
+	registerSender("webhook", func(m *Meerkat) (Sender, error) {
+		if len(m.Webhook.URLs) == 0 {
+			return nil, fmt.Errorf("webhook urls is empty")
+		}
+		s := newWebhookSender(m.Webhook, m.logger)
+		go s.worker()
+		return s, nil
+	})
+}
+
+func newWebhookSender(config WebhookConfig, logger *log.Logger) *webhookSender {
+	if config.Retries <= 0 {
+		config.Retries = 3
+	}
+	if config.DeadLetter == "" {
+		config.DeadLetter = "meerkat.deadletter"
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &webhookSender{
+		config:       config,
+		client:       &http.Client{Timeout: 30 * time.Second},
+		logger:       logger,
+		queue:        make(chan *Event, webhookQueueSize),
+		retryDelay:   webhookRetryDelay,
+		drainTimeout: webhookDrainTimeout,
+		ctx:          ctx,
+		cancel:       cancel,
+		closing:      make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+}
+
+func (s *webhookSender) Send(event *Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	if s.closed {
+		err = fmt.Errorf("webhook is closed")
+	} else {
+		select {
+		case s.queue <- event:
+			return nil
+		default:
+			err = fmt.Errorf("webhook queue is full")
+		}
+	}
+	for _, url := range s.config.URLs {
+		s.deadLetter(url, event, err)
+	}
+	return err
+}
+
+// Close stops accepting events and delivers the queued ones, without retries,
+// for at most drainTimeout. Events which are not delivered by then
+// are written to the dead letter file.
+func (s *webhookSender) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.queue)
+	s.mu.Unlock()
+
+	close(s.closing)
+	timer := time.AfterFunc(s.drainTimeout, s.cancel)
+	defer timer.Stop()
+	<-s.done
+	s.cancel()
+	return nil
+}
+
+func (s *webhookSender) worker() {
+	defer close(s.done)
+	for event := range s.queue {
+		body, err := json.Marshal(event)
+		if err != nil {
+			s.logger.Println("Error", err)
+			continue
+		}
+		for _, url := range s.config.URLs {
+			if s.ctx.Err() != nil {
+				s.deadLetter(url, event, fmt.Errorf("meerkat stopped before delivery"))
+				continue
+			}
+			if err := s.deliver(url, body); err != nil {
+				s.logger.Printf("Webhook %s failed, %s", url, err.Error())
+				s.deadLetter(url, event, err)
+			}
+		}
+	}
+}
+
+// deliver posts body to url, and retries with exponential backoff
+// on network errors and 5xx or 429 responses until the sender is closed.
+func (s *webhookSender) deliver(url string, body []byte) error {
+	var err error
+	delay := s.retryDelay
+	for attempt := 0; attempt <= s.config.Retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(delay):
+			case <-s.closing:
+				return err
+			}
+			delay *= 2
+		}
+
+		var retry bool
+		retry, err = s.post(url, body)
+		if err == nil || !retry {
+			return err
+		}
+	}
+	return err
+}
+
+func (s *webhookSender) post(url string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(s.ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "meerkat")
+	for key, value := range s.config.Headers {
+		req.Header.Set(key, value)
+	}
+	if s.config.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(s.config.Secret))
+		mac.Write(body)
+		req.Header.Set(webhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("status code %d", resp.StatusCode)
+}
+
+// deadLetter appends an undeliverable event to the dead letter file.
+func (s *webhookSender) deadLetter(url string, event *Event, reason error) {
+	line, err := json.Marshal(deadLetter{
+		URL:   url,
+		Error: reason.Error(),
+		Time:  time.Now(),
+		Event: event,
+	})
+	if err != nil {
+		s.logger.Println("Error", err)
+		return
+	}
+
+	file, err := os.OpenFile(s.config.DeadLetter, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		s.logger.Println("Error", err)
+		return
+	}
+	defer file.Close()
+	file.Write(append(line, '\n'))
+}
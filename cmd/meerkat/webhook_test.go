@@ -0,0 +1,285 @@
+package meerkat
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookServer answers requests with the given status codes in order,
+// the last one is repeated. It records the time and headers of each request.
+type webhookServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	codes   []int
+	times   []time.Time
+	headers []http.Header
+	bodies  [][]byte
+}
+
+func newWebhookServer(codes ...int) *webhookServer {
+	s := &webhookServer{codes: codes}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		s.mu.Lock()
+		n := len(s.times)
+		s.times = append(s.times, time.Now())
+		s.headers = append(s.headers, r.Header)
+		s.bodies = append(s.bodies, body)
+		code := s.codes[len(s.codes)-1]
+		if n < len(s.codes) {
+			code = s.codes[n]
+		}
+		s.mu.Unlock()
+
+		w.WriteHeader(code)
+	}))
+	return s
+}
+
+func (s *webhookServer) requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.times)
+}
+
+func testWebhookSender(t *testing.T, config WebhookConfig) *webhookSender {
+	dir, err := ioutil.TempDir("", "meerkat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	config.DeadLetter = filepath.Join(dir, "meerkat.deadletter")
+	s := newWebhookSender(config, log.New(ioutil.Discard, "", 0))
+	s.retryDelay = 10 * time.Millisecond
+	return s
+}
+
+// readDeadLetters returns the lines of the dead letter file of s.
+func readDeadLetters(t *testing.T, s *webhookSender) []deadLetter {
+	file, err := os.Open(s.config.DeadLetter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	lines := []deadLetter{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := deadLetter{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestWebhookSignature(t *testing.T) {
+	server := newWebhookServer(http.StatusOK)
+	defer server.Close()
+
+	s := testWebhookSender(t, WebhookConfig{
+		Secret:  "secret",
+		Headers: map[string]string{"X-Custom": "value"},
+	})
+	body := []byte(`{"kind":"follow"}`)
+	if err := s.deliver(server.URL, body); err != nil {
+		t.Fatal(err)
+	}
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	header := server.headers[0]
+	if got := header.Get(webhookSignatureHeader); got != want {
+		t.Errorf("signature is %q, want %q", got, want)
+	}
+	if got := header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("content type is %q", got)
+	}
+	if got := header.Get("X-Custom"); got != "value" {
+		t.Errorf("custom header is %q", got)
+	}
+	if string(server.bodies[0]) != string(body) {
+		t.Errorf("body is %s, want %s", server.bodies[0], body)
+	}
+}
+
+func TestWebhookNoSignature(t *testing.T) {
+	server := newWebhookServer(http.StatusOK)
+	defer server.Close()
+
+	s := testWebhookSender(t, WebhookConfig{})
+	if err := s.deliver(server.URL, []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.headers[0].Get(webhookSignatureHeader); got != "" {
+		t.Errorf("signature is %q without a secret", got)
+	}
+}
+
+func TestWebhookRetry(t *testing.T) {
+	tests := []struct {
+		codes    []int
+		requests int
+		failed   bool
+	}{
+		{[]int{http.StatusOK}, 1, false},
+		{[]int{http.StatusInternalServerError, http.StatusOK}, 2, false},
+		{[]int{http.StatusBadGateway, http.StatusTooManyRequests, http.StatusOK}, 3, false},
+		{[]int{http.StatusServiceUnavailable}, 4, true},
+		{[]int{http.StatusTooManyRequests}, 4, true},
+		{[]int{http.StatusBadRequest}, 1, true},
+		{[]int{http.StatusNotFound, http.StatusOK}, 1, true},
+	}
+
+	for _, test := range tests {
+		server := newWebhookServer(test.codes...)
+		s := testWebhookSender(t, WebhookConfig{Retries: 3})
+		err := s.deliver(server.URL, []byte("{}"))
+		server.Close()
+
+		if (err != nil) != test.failed {
+			t.Errorf("codes %v, error is %v", test.codes, err)
+		}
+		if got := server.requests(); got != test.requests {
+			t.Errorf("codes %v, %d requests, want %d", test.codes, got, test.requests)
+		}
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	server := newWebhookServer(http.StatusInternalServerError)
+	defer server.Close()
+
+	s := testWebhookSender(t, WebhookConfig{Retries: 3})
+	s.retryDelay = 20 * time.Millisecond
+	if err := s.deliver(server.URL, []byte("{}")); err == nil {
+		t.Fatal("delivered to a failing server")
+	}
+
+	if len(server.times) != 4 {
+		t.Fatalf("%d requests, want 4", len(server.times))
+	}
+	want := s.retryDelay
+	for i := 1; i < len(server.times); i++ {
+		if gap := server.times[i].Sub(server.times[i-1]); gap < want {
+			t.Errorf("retry %d after %s, want at least %s", i, gap, want)
+		}
+		want *= 2
+	}
+}
+
+func TestWebhookDeadLetter(t *testing.T) {
+	server := newWebhookServer(http.StatusBadRequest)
+	defer server.Close()
+
+	s := testWebhookSender(t, WebhookConfig{URLs: []string{server.URL}})
+	event := &Event{Kind: EventFollow, Username: "alice", OtherUsername: "bob"}
+	if err := s.Send(event); err != nil {
+		t.Fatal(err)
+	}
+	go s.worker()
+	s.Close()
+
+	lines := readDeadLetters(t, s)
+	if len(lines) != 1 {
+		t.Fatalf("%d dead letters, want 1", len(lines))
+	}
+	if lines[0].URL != server.URL || lines[0].Error != "status code 400" {
+		t.Errorf("dead letter is %s %q", lines[0].URL, lines[0].Error)
+	}
+	if lines[0].Event == nil || lines[0].Event.Username != "alice" || lines[0].Event.OtherUsername != "bob" {
+		t.Errorf("dead letter event is %v", lines[0].Event)
+	}
+}
+
+func TestWebhookQueueFull(t *testing.T) {
+	s := testWebhookSender(t, WebhookConfig{URLs: []string{"http://a", "http://b"}})
+	s.queue = make(chan *Event)
+
+	if err := s.Send(&Event{Kind: EventFollow}); err == nil {
+		t.Fatal("sent to a full queue")
+	}
+	data, err := ioutil.ReadFile(s.config.DeadLetter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 2 {
+		t.Errorf("%d dead letters, want 2", lines)
+	}
+}
+
+func TestWebhookCloseDrainsQueue(t *testing.T) {
+	server := newWebhookServer(http.StatusOK)
+	defer server.Close()
+
+	s := testWebhookSender(t, WebhookConfig{URLs: []string{server.URL}})
+	for i := 0; i < 5; i++ {
+		if err := s.Send(&Event{Kind: EventFollow}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	go s.worker()
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := server.requests(); got != 5 {
+		t.Errorf("%d events are delivered, want 5", got)
+	}
+	if err := s.Send(&Event{Kind: EventFollow}); err == nil {
+		t.Error("sent to a closed webhook")
+	}
+	if lines := readDeadLetters(t, s); len(lines) != 1 {
+		t.Errorf("%d dead letters, want 1", len(lines))
+	}
+}
+
+func TestWebhookCloseDeadline(t *testing.T) {
+	server := newWebhookServer(http.StatusInternalServerError)
+	defer server.Close()
+
+	s := testWebhookSender(t, WebhookConfig{URLs: []string{server.URL}, Retries: 10})
+	s.retryDelay = time.Hour
+	s.drainTimeout = 50 * time.Millisecond
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+	s.config.URLs = append(s.config.URLs, slow.URL)
+
+	for i := 0; i < 3; i++ {
+		s.Send(&Event{Kind: EventFollow})
+	}
+	go s.worker()
+
+	start := time.Now()
+	s.Close()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("close took %s", elapsed)
+	}
+
+	// every event is either failed or left for both urls
+	if lines := readDeadLetters(t, s); len(lines) != 6 {
+		t.Errorf("%d dead letters, want 6", len(lines))
+	}
+}
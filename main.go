@@ -32,7 +32,7 @@ func run() {
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "[meerkat] Error, %s\n", err.Error())
 		}
-		fmt.Fprintf(os.Stderr, "[meerkat] Logging out from Instagram , please wait ...\n")
+		fmt.Fprintf(os.Stderr, "[meerkat] Saving Instagram session , please wait ...\n")
 		m.Logout()
 		wg.Done()
 	}()